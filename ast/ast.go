@@ -1,7 +1,9 @@
 package ast
 
 import (
+	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/Gonzih/go-interpreter/token"
 )
@@ -23,6 +25,55 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	// Comments holds the comment groups in front of each statement,
+	// including the statements of nested blocks
+	Comments CommentMap
+}
+
+// CommentMap maps statements to the comment groups preceding them.
+type CommentMap map[Statement][]*CommentGroup
+
+// CommentGroup is a run of comments without an empty line between them.
+type CommentGroup struct {
+	List []token.Comment
+}
+
+// GroupComments splits comments into groups at empty lines.
+func GroupComments(comments []token.Comment) []*CommentGroup {
+	var groups []*CommentGroup
+
+	end := 0
+	for _, c := range comments {
+		if len(groups) == 0 || c.Pos.Line > end+1 {
+			groups = append(groups, &CommentGroup{})
+		}
+
+		g := groups[len(groups)-1]
+		g.List = append(g.List, c)
+		end = c.Pos.Line + strings.Count(c.Text, "\n")
+	}
+
+	return groups
+}
+
+// Text returns the text of the comments without their markers, one line
+// per line comment.
+func (g *CommentGroup) Text() string {
+	lines := make([]string, 0, len(g.List))
+
+	for _, c := range g.List {
+		text := c.Text
+		switch {
+		case strings.HasPrefix(text, "/*"):
+			text = strings.TrimSpace(strings.TrimSuffix(text[2:], "*/"))
+		default:
+			// "//" or "#!"
+			text = strings.TrimPrefix(text[2:], " ")
+		}
+		lines = append(lines, text)
+	}
+
+	return strings.Join(lines, "\n")
 }
 
 func (p *Program) TokenLiteral() string {
@@ -43,10 +94,13 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// LetStatement binds Value to Name, or destructures it with Pattern in
+// which case Name is nil.
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Pattern
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -56,7 +110,11 @@ func (ls *LetStatement) String() string {
 	var out strings.Builder
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -88,10 +146,10 @@ func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 func (rs *ReturnStatement) String() string {
 	var out strings.Builder
 
-	out.WriteString(rs.TokenLiteral() + " ")
+	out.WriteString(rs.TokenLiteral())
 
 	if rs.ReturnValue != nil {
-		out.WriteString(rs.ReturnValue.String())
+		out.WriteString(" " + rs.ReturnValue.String())
 	}
 
 	out.WriteString(";")
@@ -124,6 +182,53 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return quote(sl.Value) }
+
+// quote turns a string value back into a literal using the escape
+// sequences understood by the lexer.
+func quote(s string) string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for _, ch := range s {
+		switch ch {
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		default:
+			if unicode.IsControl(ch) {
+				fmt.Fprintf(&out, `\u{%x}`, ch)
+			} else {
+				out.WriteRune(ch)
+			}
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -137,6 +242,10 @@ func (pe *PrefixExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
+	// keep word operators apart from their operand: (not x)
+	if op := pe.Operator; op != "" && unicode.IsLetter(rune(op[len(op)-1])) {
+		out.WriteString(" ")
+	}
 	out.WriteString(pe.Right.String())
 	out.WriteString(")")
 
@@ -164,6 +273,50 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// PostfixExpression is an operator that follows its operand, Monkey has
+// none of its own but parsers can declare them.
+type PostfixExpression struct {
+	Token    token.Token
+	Operator string
+	Left     Expression
+}
+
+func (pe *PostfixExpression) expressionNode()      {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) String() string {
+	var out strings.Builder
+
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(pe.Operator)
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// AssignExpression covers plain and compound assignment, Operator is the
+// assignment operator itself, e.g. "=" or "+=".
+type AssignExpression struct {
+	Token    token.Token
+	Operator string
+	Target   Expression
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) String() string {
+	var out strings.Builder
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -191,8 +344,61 @@ func (ie *IfExpression) String() string {
 	out.WriteString(ie.Consequence.String())
 	if ie.Alternative != nil {
 		out.WriteString("else ")
-		out.WriteString(ie.Consequence.String())
+		out.WriteString(ie.Alternative.String())
+	}
+
+	return out.String()
+}
+
+// SwitchExpression evaluates to the body of the first case with a value
+// equal to Subject, or with a truthy value when there is no Subject.
+type SwitchExpression struct {
+	Token   token.Token
+	Subject Expression
+	Cases   []*SwitchCase
+}
+
+func (se *SwitchExpression) expressionNode()      {}
+func (se *SwitchExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SwitchExpression) String() string {
+	var out strings.Builder
+
+	out.WriteString("switch ")
+	if se.Subject != nil {
+		out.WriteString("(" + se.Subject.String() + ") ")
+	}
+	out.WriteString("{")
+	for _, c := range se.Cases {
+		out.WriteString(" " + c.String())
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// SwitchCase is a case of a SwitchExpression, Values is nil for the
+// default case.
+type SwitchCase struct {
+	Token  token.Token
+	Values []Expression
+	Body   *BlockStatement
+}
+
+func (sc *SwitchCase) TokenLiteral() string { return sc.Token.Literal }
+func (sc *SwitchCase) String() string {
+	var out strings.Builder
+
+	if sc.Values == nil {
+		out.WriteString("default")
+	} else {
+		values := []string{}
+		for _, v := range sc.Values {
+			values = append(values, v.String())
+		}
+		out.WriteString("case " + strings.Join(values, ", "))
 	}
+	out.WriteString(": ")
+	out.WriteString(sc.Body.String())
 
 	return out.String()
 }
@@ -217,7 +423,14 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// Defaults holds the default value of each parameter, nil for the
+	// required ones. It is nil when no parameter has a default.
+	Defaults []Expression
+	// Rest collects the arguments after the parameters, if any
+	Rest *Identifier
+	Body *BlockStatement
+	// Name is the let binding the literal is assigned to, if any
+	Name string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -226,8 +439,15 @@ func (fl *FunctionLiteral) String() string {
 	var out strings.Builder
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if fl.Defaults != nil && fl.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+fl.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -239,10 +459,84 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out strings.Builder
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out strings.Builder
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral keeps its pairs in source order, keys are arbitrary
+// expressions.
+type HashLiteral struct {
+	Token token.Token
+	Pairs []HashPair
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	var out strings.Builder
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// CallExpression passes the Arguments by position, they may contain a
+// SpreadExpression, followed by the Named arguments.
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Named     []*NamedArgument
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -254,6 +548,9 @@ func (ce *CallExpression) String() string {
 	for _, a := range ce.Arguments {
 		params = append(params, a.String())
 	}
+	for _, a := range ce.Named {
+		params = append(params, a.String())
+	}
 
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
@@ -262,3 +559,134 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+// SpreadExpression "...xs" passes the elements of an array as separate
+// arguments.
+type SpreadExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// NamedArgument "name: value" passes value for the parameter name.
+type NamedArgument struct {
+	Token token.Token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string       { return na.Name.String() + ": " + na.Value.String() }
+
+// BadExpression is a placeholder for an expression that failed to parse,
+// Token is the token the parser was at when the error was found.
+type BadExpression struct {
+	Token token.Token
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
+// BadStatement is a placeholder for a statement that failed to parse,
+// Token is the first token of the statement.
+type BadStatement struct {
+	Token token.Token
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) String() string {
+	var out strings.Builder
+
+	out.WriteString("while (")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForStatement is a C-style for loop, Init, Condition and Post are nil
+// when they are omitted.
+type ForStatement struct {
+	Token     token.Token
+	Init      Statement
+	Condition Expression
+	Post      Expression
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) String() string {
+	var out strings.Builder
+
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Post != nil {
+		out.WriteString(fs.Post.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type ForInStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) String() string {
+	var out strings.Builder
+
+	out.WriteString("for ")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(" ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
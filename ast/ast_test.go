@@ -26,3 +26,50 @@ func TestString(t *testing.T) {
 
 	assert.Equal(t, "let myVar = anotherVar;", program.String())
 }
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"hello", `"hello"`},
+		{"a\nb\tc", `"a\nb\tc"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+		{"bell\a", `"bell\u{7}"`},
+		{"café", `"café"`},
+	}
+
+	for _, tt := range tests {
+		lit := &StringLiteral{
+			Token: token.Token{Type: token.STRING, Literal: tt.value},
+			Value: tt.value,
+		}
+		assert.Equal(t, tt.expected, lit.String())
+	}
+}
+
+func TestGroupComments(t *testing.T) {
+	c := func(line int, text string) token.Comment {
+		return token.Comment{Pos: token.Position{Line: line, Column: 1}, Text: text}
+	}
+
+	groups := GroupComments([]token.Comment{
+		c(1, "#!/usr/bin/env monkey"),
+		c(3, "// first"),
+		c(4, "/* second\n   line */"),
+		c(7, "// third"),
+		c(9, "//   indented"),
+		c(9, "/**/"),
+	})
+
+	assert.Len(t, groups, 4)
+	if len(groups) == 4 {
+		assert.Equal(t, "/usr/bin/env monkey", groups[0].Text())
+		assert.Equal(t, "first\nsecond\n   line", groups[1].Text())
+		assert.Equal(t, "third", groups[2].Text())
+		assert.Equal(t, "  indented\n", groups[3].Text())
+	}
+
+	assert.Empty(t, GroupComments(nil))
+}
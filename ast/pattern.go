@@ -0,0 +1,167 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/Gonzih/go-interpreter/token"
+)
+
+// Pattern is matched against a value, binding names to the parts of it.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// LiteralPattern matches a value equal to an integer, float, string or
+// boolean literal, numbers can be negated.
+type LiteralPattern struct {
+	Token token.Token
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) String() string {
+	// a negated number prints as a literal, patterns have no parentheses
+	if pe, ok := lp.Value.(*PrefixExpression); ok {
+		return pe.Operator + pe.Right.String()
+	}
+
+	return lp.Value.String()
+}
+
+// WildcardPattern "_" matches any value without binding it.
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (wp *WildcardPattern) patternNode()         {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) String() string       { return "_" }
+
+// BindingPattern matches any value and binds it to Name.
+type BindingPattern struct {
+	Token token.Token
+	Name  *Identifier
+}
+
+func (bp *BindingPattern) patternNode()         {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Token.Literal }
+func (bp *BindingPattern) String() string       { return bp.Name.String() }
+
+// RestPattern "...name" matches the remaining elements of an array or the
+// remaining pairs of a hash, Name is nil when they are not bound.
+type RestPattern struct {
+	Token token.Token
+	Name  *Identifier
+}
+
+func (rp *RestPattern) patternNode()         {}
+func (rp *RestPattern) TokenLiteral() string { return rp.Token.Literal }
+func (rp *RestPattern) String() string {
+	if rp.Name == nil {
+		return "..."
+	}
+
+	return "..." + rp.Name.String()
+}
+
+// ArrayPattern matches an array element by element, it matches arrays of
+// the same length, or of at least that length when there is a Rest.
+type ArrayPattern struct {
+	Token    token.Token
+	Elements []Pattern
+	Rest     *RestPattern
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type HashPatternPair struct {
+	Key   Expression
+	Value Pattern
+}
+
+// HashPattern matches a hash having all of the keys, with the values
+// matching their patterns. Other keys are ignored unless there is a Rest.
+type HashPattern struct {
+	Token token.Token
+	Pairs []HashPatternPair
+	Rest  *RestPattern
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		// {name} is short for {"name": name}
+		key, ok := pair.Key.(*StringLiteral)
+		if value, isBinding := pair.Value.(*BindingPattern); ok && isBinding && key.Value == value.Name.Value {
+			pairs = append(pairs, value.String())
+			continue
+		}
+
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+	if hp.Rest != nil {
+		pairs = append(pairs, hp.Rest.String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// GuardPattern matches when Pattern matches and Guard, evaluated with
+// the names bound by Pattern, is truthy.
+type GuardPattern struct {
+	Token   token.Token
+	Pattern Pattern
+	Guard   Expression
+}
+
+func (gp *GuardPattern) patternNode()         {}
+func (gp *GuardPattern) TokenLiteral() string { return gp.Token.Literal }
+func (gp *GuardPattern) String() string {
+	return gp.Pattern.String() + " if " + gp.Guard.String()
+}
+
+// MatchArm is a pattern and the expression the match evaluates to when it
+// is the first arm matching.
+type MatchArm struct {
+	Pattern Pattern
+	Body    Expression
+}
+
+func (ma *MatchArm) String() string {
+	return ma.Pattern.String() + " => " + ma.Body.String()
+}
+
+// MatchExpression evaluates the body of the first arm whose pattern
+// matches Subject.
+type MatchExpression struct {
+	Token   token.Token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	return "match " + me.Subject.String() + " { " + strings.Join(arms, ", ") + " }"
+}
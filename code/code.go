@@ -0,0 +1,254 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+// String disassembles the instructions, one per line, prefixed with the
+// byte offset of each instruction.
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpDup
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpGreaterEqual
+
+	OpMinus
+	OpBang
+
+	OpJumpNotTruthy
+	OpJump
+	OpJumpIfLocalSet
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetFree
+
+	OpCall
+	OpCallArgs
+	OpReturnValue
+	OpReturn
+
+	OpClosure
+	OpCurrentClosure
+
+	OpArray
+	OpConcat
+	OpHash
+	OpIndex
+	OpSetIndex
+
+	OpIter
+	OpIterNext
+
+	OpMatch
+	OpDestructure
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	// number of values on top of the stack to push again
+	OpDup: {"OpDup", []int{1}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+	OpPow: {"OpPow", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+	// local index and the position to jump to when the local is set, a
+	// parameter skips its default this way
+	OpJumpIfLocalSet: {"OpJumpIfLocalSet", []int{1, 2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{1}},
+	OpSetLocal:  {"OpSetLocal", []int{1}},
+	OpGetFree:   {"OpGetFree", []int{1}},
+
+	OpCall: {"OpCall", []int{1}},
+	// constant index of the argument names, pops the named values and an
+	// array of the positional arguments, then calls like OpCall
+	OpCallArgs:    {"OpCallArgs", []int{2}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	// constant index of the function, number of free variables
+	OpClosure:        {"OpClosure", []int{2, 1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	// number of elements taken from the stack
+	OpArray: {"OpArray", []int{2}},
+	// number of arrays taken from the stack and joined into one
+	OpConcat: {"OpConcat", []int{2}},
+	// number of keys and values taken from the stack
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+	// pops the collection, the index and the value, pushes the value
+	OpSetIndex: {"OpSetIndex", []int{}},
+
+	// replaces the value on the stack with an iterator over it
+	OpIter: {"OpIter", []int{}},
+	// pops an iterator, pushes its next item and true or only false
+	OpIterNext: {"OpIterNext", []int{}},
+
+	// constant index of the pattern, pops the value and pushes the values
+	// bound by the pattern and true when it matches, only false otherwise
+	OpMatch: {"OpMatch", []int{2}},
+	// like OpMatch without the boolean, a value that does not match is an
+	// error
+	OpDestructure: {"OpDestructure", []int{2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes a single instruction, operands are written big-endian using
+// the widths from the opcode definition.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands is the inverse of Make, it returns decoded operands and the
+// number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
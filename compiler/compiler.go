@@ -0,0 +1,929 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/Gonzih/go-interpreter/ast"
+	"github.com/Gonzih/go-interpreter/code"
+	"github.com/Gonzih/go-interpreter/object"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	// loops being compiled, innermost last
+	loops []*loop
+}
+
+// loop collects the jumps of the break and continue statements of a loop,
+// they are patched once the loop is compiled.
+type loop struct {
+	breaks    []int
+	continues []int
+}
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions: code.Instructions{},
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+	}
+}
+
+// NewWithState creates a compiler that keeps on using the given symbol table
+// and constants, used by the REPL to preserve globals between lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+
+	return compiler
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			if err := c.Compile(node.Value); err != nil {
+				return err
+			}
+			c.emit(code.OpDestructure, c.addConstant(&object.Pattern{Pattern: node.Pattern}))
+			c.bindPattern(node.Pattern)
+			return nil
+		}
+		// defined after the value, which still sees an earlier binding of
+		// the name. A function refers to itself through FunctionScope, it
+		// is defined first so that it can assign to its global binding.
+		fn, ok := node.Value.(*ast.FunctionLiteral)
+		if ok && fn.Name == node.Name.Value {
+			c.symbolTable.Define(node.Name.Value)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.setSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.WhileStatement:
+		start := len(c.currentInstructions())
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileLoopBody(node.Body, nil, start); err != nil {
+			return err
+		}
+		c.changeOperand(exit, len(c.currentInstructions()))
+
+	case *ast.ForStatement:
+		if node.Init != nil {
+			if err := c.Compile(node.Init); err != nil {
+				return err
+			}
+		}
+
+		start := len(c.currentInstructions())
+		exit := -1
+		if node.Condition != nil {
+			if err := c.Compile(node.Condition); err != nil {
+				return err
+			}
+			exit = c.emit(code.OpJumpNotTruthy, 9999)
+		}
+
+		if err := c.compileLoopBody(node.Body, node.Post, start); err != nil {
+			return err
+		}
+		if exit >= 0 {
+			c.changeOperand(exit, len(c.currentInstructions()))
+		}
+
+	case *ast.ForInStatement:
+		if err := c.Compile(node.Iterable); err != nil {
+			return err
+		}
+		c.emit(code.OpIter)
+
+		iterator := c.symbolTable.DefineTemp()
+		c.setSymbol(iterator)
+
+		start := len(c.currentInstructions())
+		c.loadSymbol(iterator)
+		c.emit(code.OpIterNext)
+		exit := c.emit(code.OpJumpNotTruthy, 9999)
+		c.setSymbol(c.symbolTable.Define(node.Variable.Value))
+
+		if err := c.compileLoopBody(node.Body, nil, start); err != nil {
+			return err
+		}
+		c.changeOperand(exit, len(c.currentInstructions()))
+
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return fmt.Errorf("%s outside of a loop", node.TokenLiteral())
+		}
+
+		l := loops[len(loops)-1]
+		jump := c.emit(code.OpJump, 9999)
+		if _, ok := node.(*ast.BreakStatement); ok {
+			l.breaks = append(l.breaks, jump)
+		} else {
+			l.continues = append(l.continues, jump)
+		}
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			c.emit(code.OpReturn)
+			return nil
+		}
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		switch node.Operator {
+		case "&&", "||":
+			return c.compileLogicalExpression(node)
+		case "<", "<=":
+			// there are no less than opcodes, the operands are swapped and
+			// compiled as > or >=
+			if err := c.Compile(node.Right); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Left); err != nil {
+				return err
+			}
+			if node.Operator == "<" {
+				c.emit(code.OpGreaterThan)
+			} else {
+				c.emit(code.OpGreaterEqual)
+			}
+			return nil
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "+":
+			c.emit(code.OpAdd)
+		case "-":
+			c.emit(code.OpSub)
+		case "*":
+			c.emit(code.OpMul)
+		case "/":
+			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
+		case "**":
+			c.emit(code.OpPow)
+		case ">":
+			c.emit(code.OpGreaterThan)
+		case ">=":
+			c.emit(code.OpGreaterEqual)
+		case "==":
+			c.emit(code.OpEqual)
+		case "!=":
+			c.emit(code.OpNotEqual)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.IfExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		// bogus offset, patched once the consequence is compiled
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.Compile(node.Consequence); err != nil {
+			return err
+		}
+		c.leaveBlockValue()
+
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		afterConsequencePos := len(c.currentInstructions())
+		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else {
+			if err := c.Compile(node.Alternative); err != nil {
+				return err
+			}
+			c.leaveBlockValue()
+		}
+
+		afterAlternativePos := len(c.currentInstructions())
+		c.changeOperand(jumpPos, afterAlternativePos)
+
+	case *ast.SwitchExpression:
+		return c.compileSwitchExpression(node)
+
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(node)
+
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("undefined variable %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.FunctionLiteral:
+		c.enterScope()
+
+		if node.Name != "" {
+			c.symbolTable.DefineFunctionName(node.Name)
+		}
+
+		params := []string{}
+		for _, p := range node.Parameters {
+			c.symbolTable.Define(p.Value)
+			params = append(params, p.Value)
+		}
+		if node.Rest != nil {
+			c.symbolTable.Define(node.Rest.Value)
+		}
+
+		required, err := c.compileDefaults(node)
+		if err != nil {
+			return err
+		}
+
+		if err := c.Compile(node.Body); err != nil {
+			return err
+		}
+
+		if c.lastInstructionIs(code.OpPop) {
+			c.replaceLastPopWithReturn()
+		}
+		if !c.lastInstructionIs(code.OpReturnValue) && !c.lastInstructionIs(code.OpReturn) {
+			c.emit(code.OpReturn)
+		}
+
+		freeSymbols := c.symbolTable.FreeSymbols
+		numLocals := c.symbolTable.numDefinitions
+		instructions := c.leaveScope()
+
+		for _, s := range freeSymbols {
+			c.loadSymbol(s)
+		}
+
+		compiledFn := &object.CompiledFunction{
+			Instructions:  instructions,
+			NumLocals:     numLocals,
+			NumParameters: len(node.Parameters),
+			Parameters:    params,
+			Required:      required,
+			Rest:          node.Rest != nil,
+		}
+
+		fnIndex := c.addConstant(compiledFn)
+		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		if hasSpread(node.Arguments) || len(node.Named) > 0 {
+			return c.compileCallArgs(node)
+		}
+
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return fmt.Errorf("unsupported node %T", node)
+	}
+
+	return nil
+}
+
+// compileDefaults emits the prologue of a function that sets the
+// parameters left out of a call to their defaults, in order. It returns
+// the number of required parameters before them.
+func (c *Compiler) compileDefaults(fn *ast.FunctionLiteral) (int, error) {
+	required := len(fn.Parameters)
+	for required > 0 && fn.Defaults != nil && fn.Defaults[required-1] != nil {
+		required--
+	}
+
+	for i := required; i < len(fn.Parameters); i++ {
+		jumpPos := c.emit(code.OpJumpIfLocalSet, i, 9999)
+
+		if err := c.Compile(fn.Defaults[i]); err != nil {
+			return 0, err
+		}
+		c.emit(code.OpSetLocal, i)
+
+		c.replaceInstruction(jumpPos, code.Make(code.OpJumpIfLocalSet, i, len(c.currentInstructions())))
+	}
+
+	return required, nil
+}
+
+// compileCallArgs emits a call with spread or named arguments: the
+// positional arguments are collected in one array, plain ones in between
+// spreads grouped into arrays of their own, followed by the named values.
+func (c *Compiler) compileCallArgs(node *ast.CallExpression) error {
+	arrays, pending := 0, 0
+	for _, a := range node.Arguments {
+		spread, ok := a.(*ast.SpreadExpression)
+		if !ok {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+			pending++
+			continue
+		}
+
+		if pending > 0 {
+			c.emit(code.OpArray, pending)
+			arrays++
+			pending = 0
+		}
+		if err := c.Compile(spread.Value); err != nil {
+			return err
+		}
+		arrays++
+	}
+
+	if pending > 0 || arrays == 0 {
+		c.emit(code.OpArray, pending)
+		arrays++
+	}
+	if arrays > 1 {
+		c.emit(code.OpConcat, arrays)
+	}
+
+	names := &object.Array{Elements: []object.Object{}}
+	for _, arg := range node.Named {
+		if err := c.Compile(arg.Value); err != nil {
+			return err
+		}
+		names.Elements = append(names.Elements, &object.String{Value: arg.Name.Value})
+	}
+
+	c.emit(code.OpCallArgs, c.addConstant(names))
+
+	return nil
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, a := range args {
+		if _, ok := a.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileLoopBody emits the body of a loop, then post if there is one, and
+// jumps back to start. Continue jumps to post, or to start without it, and
+// break jumps past the loop.
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, post ast.Expression, start int) error {
+	l := &loop{}
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, l)
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+
+	next := len(c.currentInstructions())
+	if post != nil {
+		if err := c.Compile(post); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, start)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range l.continues {
+		c.changeOperand(pos, next)
+	}
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileSwitchExpression emits the tests of all cases first, each one
+// jumping to the body of its case when it passes, followed by the bodies.
+// The subject is kept in a slot of its own while the cases are tested.
+func (c *Compiler) compileSwitchExpression(node *ast.SwitchExpression) error {
+	var subject Symbol
+	if node.Subject != nil {
+		if err := c.Compile(node.Subject); err != nil {
+			return err
+		}
+		subject = c.symbolTable.DefineTemp()
+		c.setSymbol(subject)
+	}
+
+	toBody := make([][]int, len(node.Cases))
+	defaultCase := -1
+
+	for i, sc := range node.Cases {
+		if sc.Values == nil {
+			defaultCase = i
+			continue
+		}
+
+		for _, v := range sc.Values {
+			if node.Subject != nil {
+				c.loadSymbol(subject)
+			}
+			if err := c.Compile(v); err != nil {
+				return err
+			}
+			if node.Subject != nil {
+				c.emit(code.OpEqual)
+			}
+
+			next := c.emit(code.OpJumpNotTruthy, 9999)
+			toBody[i] = append(toBody[i], c.emit(code.OpJump, 9999))
+			c.changeOperand(next, len(c.currentInstructions()))
+		}
+	}
+
+	// no case matched
+	toEnd := []int{}
+	if defaultCase >= 0 {
+		toBody[defaultCase] = append(toBody[defaultCase], c.emit(code.OpJump, 9999))
+	} else {
+		c.emit(code.OpNull)
+		toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+	}
+
+	for i, sc := range node.Cases {
+		for _, pos := range toBody[i] {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+
+		if err := c.Compile(sc.Body); err != nil {
+			return err
+		}
+		c.leaveBlockValue()
+
+		if i < len(node.Cases)-1 {
+			toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+		}
+	}
+
+	for _, pos := range toEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileMatchExpression tests the arms in order, the subject is kept in
+// a slot of its own. The names an arm binds are set like a let would before
+// its guard runs, when no arm matches the match evaluates to null.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	if err := c.Compile(node.Subject); err != nil {
+		return err
+	}
+	subject := c.symbolTable.DefineTemp()
+	c.setSymbol(subject)
+
+	toEnd := []int{}
+	for _, arm := range node.Arms {
+		c.loadSymbol(subject)
+		c.emit(code.OpMatch, c.addConstant(&object.Pattern{Pattern: arm.Pattern}))
+		next := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+
+		c.bindPattern(arm.Pattern)
+
+		if guard, ok := arm.Pattern.(*ast.GuardPattern); ok {
+			if err := c.Compile(guard.Guard); err != nil {
+				return err
+			}
+			next = append(next, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		if err := c.Compile(arm.Body); err != nil {
+			return err
+		}
+		toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+
+		for _, pos := range next {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+	}
+
+	c.emit(code.OpNull)
+
+	for _, pos := range toEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// bindPattern defines the names bound by pattern and sets them to the
+// values OpMatch or OpDestructure left on the stack, the last one on top.
+func (c *Compiler) bindPattern(pattern ast.Pattern) {
+	names := object.Bindings(pattern)
+
+	symbols := make([]Symbol, len(names))
+	for i, name := range names {
+		symbols[i] = c.symbolTable.Define(name)
+	}
+
+	for i := len(symbols) - 1; i >= 0; i-- {
+		c.setSymbol(symbols[i])
+	}
+}
+
+// compoundOperators maps compound assignment operators to the opcode that
+// combines the current value of the target with the assigned value.
+var compoundOperators = map[string]code.Opcode{
+	"+=": code.OpAdd,
+	"-=": code.OpSub,
+	"*=": code.OpMul,
+	"/=": code.OpDiv,
+}
+
+// compileAssignExpression leaves the assigned value on the stack. The
+// target and index of an index assignment are evaluated once, a compound
+// assignment reads the current value through copies of them.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	op, compound := compoundOperators[node.Operator]
+	if !compound && node.Operator != "=" {
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, err := c.resolveAssignable(target.Value)
+		if err != nil {
+			return err
+		}
+
+		if compound {
+			c.loadSymbol(symbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+
+		c.setSymbol(symbol)
+		c.loadSymbol(symbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if compound {
+			c.emit(code.OpDup, 2)
+			c.emit(code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+
+		c.emit(code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// resolveAssignable resolves a variable that is assigned to. Closures get
+// a copy of the variables they capture, so those can not be assigned to,
+// except for globals that are shared.
+func (c *Compiler) resolveAssignable(name string) (Symbol, error) {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		return symbol, fmt.Errorf("undefined variable %s", name)
+	}
+
+	switch symbol.Scope {
+	case GlobalScope, LocalScope:
+		return symbol, nil
+	case FunctionScope:
+		// the function itself, which can be bound to a global
+		if outer, _ := c.symbolTable.Outer.Resolve(name); outer.Scope == GlobalScope {
+			return outer, nil
+		}
+	}
+
+	return symbol, fmt.Errorf("cannot assign to captured variable %s", name)
+}
+
+// compileLogicalExpression emits short-circuiting code for && and ||, the
+// result is always a boolean.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	// forward jumps, patched once the positions of false and end are known
+	toFalse := []int{}
+	toEnd := []int{}
+
+	if node.Operator == "&&" {
+		toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	} else {
+		rightPos := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+		c.changeOperand(rightPos, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	c.emit(code.OpTrue)
+	toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+
+	falsePos := c.emit(code.OpFalse)
+	endPos := len(c.currentInstructions())
+
+	for _, pos := range toFalse {
+		c.changeOperand(pos, falsePos)
+	}
+	for _, pos := range toEnd {
+		c.changeOperand(pos, endPos)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	c.scopes[c.scopeIndex].instructions = old[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+// leaveBlockValue keeps the value of the last expression of a block on the
+// stack, blocks that do not end in an expression produce null.
+func (c *Compiler) leaveBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions: code.Instructions{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) setSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
@@ -0,0 +1,1066 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/Gonzih/go-interpreter/ast"
+	"github.com/Gonzih/go-interpreter/code"
+	"github.com/Gonzih/go-interpreter/lexer"
+	"github.com/Gonzih/go-interpreter/object"
+	"github.com/Gonzih/go-interpreter/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// patternConstant is the source of a pattern in the constants.
+type patternConstant string
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+
+	return out
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		assert.NoError(t, err, tt.input)
+		if err != nil {
+			t.FailNow()
+		}
+
+		bytecode := compiler.Bytecode()
+
+		// compare disassembled strings, the diff is much easier to read
+		assert.Equal(t,
+			concatInstructions(tt.expectedInstructions).String(),
+			bytecode.Instructions.String(),
+			tt.input,
+		)
+		testConstants(t, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func testConstants(t *testing.T, expected []interface{}, actual []object.Object) {
+	assert.Len(t, actual, len(expected))
+	if len(actual) != len(expected) {
+		t.FailNow()
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			assert.True(t, ok, "constant %d is not Integer, got %T", i, actual[i])
+			if ok {
+				assert.Equal(t, int64(constant), integer.Value)
+			}
+		case float64:
+			float, ok := actual[i].(*object.Float)
+			assert.True(t, ok, "constant %d is not Float, got %T", i, actual[i])
+			if ok {
+				assert.Equal(t, constant, float.Value)
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			assert.True(t, ok, "constant %d is not String, got %T", i, actual[i])
+			if ok {
+				assert.Equal(t, constant, str.Value)
+			}
+		case patternConstant:
+			pattern, ok := actual[i].(*object.Pattern)
+			assert.True(t, ok, "constant %d is not Pattern, got %T", i, actual[i])
+			if ok {
+				assert.Equal(t, string(constant), pattern.Inspect())
+			}
+		case []string:
+			array, ok := actual[i].(*object.Array)
+			assert.True(t, ok, "constant %d is not Array, got %T", i, actual[i])
+			if ok {
+				assert.Equal(t, len(constant), len(array.Elements))
+				for j, el := range array.Elements {
+					assert.Equal(t, constant[j], el.Inspect())
+				}
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			assert.True(t, ok, "constant %d is not CompiledFunction, got %T", i, actual[i])
+			if ok {
+				assert.Equal(t, concatInstructions(constant).String(), fn.Instructions.String())
+			}
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 / 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDiv),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 ** 3 ** 2",
+			expectedConstants: []interface{}{2, 3, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPow),
+				code.Make(code.OpPow),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1.5 * 2",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 < 2",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true != false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpFalse),
+				code.Make(code.OpNotEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "!true",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"mon" + "key"`,
+			expectedConstants: []interface{}{"mon", "key"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[]",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2][1 - 1]",
+			expectedConstants: []interface{}{1, 2, 1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSub),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "{}",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{1: 2 + 3}[1]",
+			expectedConstants: []interface{}{1, 2, 3, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0008
+				code.Make(code.OpTrue),
+				// 0009
+				code.Make(code.OpJump, 13),
+				// 0012
+				code.Make(code.OpFalse),
+				// 0013
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 8),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJump, 17),
+				// 0008
+				code.Make(code.OpFalse),
+				// 0009
+				code.Make(code.OpJumpNotTruthy, 16),
+				// 0012
+				code.Make(code.OpTrue),
+				// 0013
+				code.Make(code.OpJump, 17),
+				// 0016
+				code.Make(code.OpFalse),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 >= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 <= 2",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterEqual),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (true) { 10 } else { 20 }; 3333;",
+			expectedConstants: []interface{}{10, 20, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 13),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpConstant, 2),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "switch (1) { case 2, 3: 4; default: 5 }",
+			expectedConstants: []interface{}{1, 2, 3, 4, 5},
+			expectedInstructions: []code.Instructions{
+				// 0000, the subject is kept in a slot of its own
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpEqual),
+				// 0013
+				code.Make(code.OpJumpNotTruthy, 19),
+				// 0016
+				code.Make(code.OpJump, 35),
+				// 0019
+				code.Make(code.OpGetGlobal, 0),
+				// 0022
+				code.Make(code.OpConstant, 2),
+				// 0025
+				code.Make(code.OpEqual),
+				// 0026
+				code.Make(code.OpJumpNotTruthy, 32),
+				// 0029
+				code.Make(code.OpJump, 35),
+				// 0032, no case matched
+				code.Make(code.OpJump, 41),
+				// 0035
+				code.Make(code.OpConstant, 3),
+				// 0038
+				code.Make(code.OpJump, 44),
+				// 0041
+				code.Make(code.OpConstant, 4),
+				// 0044
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "switch { case true: 1 }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 7),
+				// 0004
+				code.Make(code.OpJump, 11),
+				// 0007, no case matched
+				code.Make(code.OpNull),
+				// 0008
+				code.Make(code.OpJump, 14),
+				// 0011
+				code.Make(code.OpConstant, 0),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "match 1 { x if x > 0 => x, _ => 0 }",
+			expectedConstants: []interface{}{1, patternConstant("x if (x > 0)"), 0, patternConstant("_"), 0},
+			expectedInstructions: []code.Instructions{
+				// 0000, the subject is kept in a slot of its own
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpMatch, 1),
+				// 0012
+				code.Make(code.OpJumpNotTruthy, 34),
+				// 0015, x is bound before the guard runs
+				code.Make(code.OpSetGlobal, 1),
+				// 0018
+				code.Make(code.OpGetGlobal, 1),
+				// 0021
+				code.Make(code.OpConstant, 2),
+				// 0024
+				code.Make(code.OpGreaterThan),
+				// 0025
+				code.Make(code.OpJumpNotTruthy, 34),
+				// 0028
+				code.Make(code.OpGetGlobal, 1),
+				// 0031
+				code.Make(code.OpJump, 50),
+				// 0034
+				code.Make(code.OpGetGlobal, 0),
+				// 0037
+				code.Make(code.OpMatch, 3),
+				// 0040
+				code.Make(code.OpJumpNotTruthy, 49),
+				// 0043
+				code.Make(code.OpConstant, 4),
+				// 0046
+				code.Make(code.OpJump, 50),
+				// 0049, no arm matched
+				code.Make(code.OpNull),
+				// 0050
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "match [1, 2] { [a, b] => b }",
+			expectedConstants: []interface{}{1, 2, patternConstant("[a, b]")},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpMatch, 2),
+				code.Make(code.OpJumpNotTruthy, 33),
+				// b is on top of a
+				code.Make(code.OpSetGlobal, 2),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpJump, 34),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { if (false) { break; } continue; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 23),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 15),
+				// 0008
+				code.Make(code.OpJump, 23),
+				// 0011
+				code.Make(code.OpNull),
+				// 0012
+				code.Make(code.OpJump, 16),
+				// 0015
+				code.Make(code.OpNull),
+				// 0016
+				code.Make(code.OpPop),
+				// 0017
+				code.Make(code.OpJump, 20),
+				// 0020
+				code.Make(code.OpJump, 0),
+			},
+		},
+		{
+			input:             "for (let i = 0; i; i) { continue; }",
+			expectedConstants: []interface{}{0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpJumpNotTruthy, 22),
+				// 0012, continue runs the post expression
+				code.Make(code.OpJump, 15),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpPop),
+				// 0019
+				code.Make(code.OpJump, 6),
+			},
+		},
+		{
+			input:             "for x in [1] { x }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIter),
+				// 0007, the iterator is kept in a slot of its own
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpGetGlobal, 0),
+				// 0013
+				code.Make(code.OpIterNext),
+				// 0014
+				code.Make(code.OpJumpNotTruthy, 27),
+				// 0017
+				code.Make(code.OpSetGlobal, 1),
+				// 0020
+				code.Make(code.OpGetGlobal, 1),
+				// 0023
+				code.Make(code.OpPop),
+				// 0024
+				code.Make(code.OpJump, 10),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x += 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] *= 2",
+			expectedConstants: []interface{}{1, 0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDup, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMul),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn(x) { x = 1 }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "undefined variable x"},
+		{"fn(a) { fn() { a = 1 } }", "cannot assign to captured variable a"},
+		{"fn() { let f = fn() { f = 1 } }", "cannot assign to captured variable f"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = one; two;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// the value still reads the first x
+			input:             "let x = 1; let x = x + 1; x",
+			expectedConstants: []interface{}{1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn() { return 5 + 10 }",
+			expectedConstants: []interface{}{
+				5,
+				10,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { 1; 2 }",
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { return\n}",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let oneArg = fn(a) { a }; oneArg(24);",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				24,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// a local shadowing the parameter is read from the parameter
+			input: "fn(x) { let x = x + 1; x }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "let countDown = fn(x) { countDown(x - 1); }; countDown(1);",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSub),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "c", Scope: FreeScope, Index: 0},
+		{Name: "e", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		assert.True(t, ok, sym.Name)
+		assert.Equal(t, sym, result)
+	}
+
+	assert.Equal(t, []Symbol{{Name: "c", Scope: LocalScope, Index: 0}}, secondLocal.FreeSymbols)
+
+	_, ok := secondLocal.Resolve("unknown")
+	assert.False(t, ok)
+}
+
+func TestUndefinedVariable(t *testing.T) {
+	compiler := New()
+	err := compiler.Compile(parse("x"))
+	assert.EqualError(t, err, "undefined variable x")
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let [a, b] = [1, 2]; b",
+			expectedConstants: []interface{}{1, 2, patternConstant("[a, b]")},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpDestructure, 2),
+				// b is on top of a
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { let {a} = {}; a }",
+			expectedConstants: []interface{}{
+				patternConstant("{a}"),
+				[]code.Instructions{
+					code.Make(code.OpHash, 0),
+					code.Make(code.OpDestructure, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a, b = 1) { a + b }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpJumpIfLocalSet, 1, 9),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn(a, ...rest) { rest }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let f = fn(a) { a }; f(a: 1)",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]string{"a"},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCallArgs, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let f = fn(a, b, c = 0) { a }; let xs = [1]; f(...xs, 2, c: 3)",
+			expectedConstants: []interface{}{
+				0,
+				[]code.Instructions{
+					code.Make(code.OpJumpIfLocalSet, 2, 9),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				2,
+				3,
+				[]string{"c"},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConcat, 2),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpCallArgs, 5),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
@@ -0,0 +1,109 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	numDefinitions int
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+
+	return s
+}
+
+// Define binds name in this table. Defining a name again in the same
+// table reuses its slot, so a let in a loop body updates the binding the
+// next iteration reads, like it does in the evaluator.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope == s.scope() {
+		return symbol
+	}
+
+	symbol := s.DefineTemp()
+	symbol.Name = name
+	s.store[name] = symbol
+
+	return symbol
+}
+
+// DefineTemp reserves a slot without a name, for values the compiler keeps
+// around itself like the iterator of a for-in loop.
+func (s *SymbolTable) DefineTemp() Symbol {
+	symbol := Symbol{Index: s.numDefinitions, Scope: s.scope()}
+	s.numDefinitions++
+
+	return symbol
+}
+
+func (s *SymbolTable) scope() SymbolScope {
+	if s.Outer == nil {
+		return GlobalScope
+	}
+
+	return LocalScope
+}
+
+// DefineFunctionName binds the name of the function being compiled so that
+// it can refer to itself without capturing a not yet initialised binding.
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+
+	return symbol
+}
+
+// Resolve looks the name up through the enclosing tables, locals of an
+// enclosing function are turned into free symbols of this one.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+
+		if obj.Scope == GlobalScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+
+	return obj, ok
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+
+	return symbol
+}
@@ -0,0 +1,841 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Gonzih/go-interpreter/ast"
+	"github.com/Gonzih/go-interpreter/object"
+)
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	// Statements
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if node.Pattern != nil {
+			return evalDestructuring(node.Pattern, val, env)
+		}
+		env.Set(node.Name.Value, val)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.InfixExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.SwitchExpression:
+		return evalSwitchExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Defaults: node.Defaults,
+			Rest: node.Rest, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalArguments(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		names := []string{}
+		named := []object.Object{}
+		for _, arg := range node.Named {
+			value := Eval(arg.Value, env)
+			if isError(value) {
+				return value
+			}
+			names = append(names, arg.Name.Value)
+			named = append(named, value)
+		}
+		return applyFunction(function, args, names, named)
+	default:
+		return newError("unsupported node: %T", node)
+	}
+
+	return nil
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		// return values are passed up unwrapped so that the enclosing
+		// function (or program) can stop evaluation at the right level,
+		// break and continue stop at the enclosing loop
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
+				return result
+			}
+		}
+	}
+
+	// an empty block, or one ending in a let, has no value
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+
+	return FALSE
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	switch right {
+	case TRUE:
+		return FALSE
+	case FALSE:
+		return TRUE
+	case NULL:
+		return TRUE
+	default:
+		return FALSE
+	}
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
+
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: -%s", right.Type())
+	}
+
+	value := right.(*object.Integer).Value
+
+	return &object.Integer{Value: -value}
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return newError("negative exponent: %d", rightVal)
+		}
+		return &object.Integer{Value: object.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression handles floats and integers mixed with floats,
+// integers are converted to floats first.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := object.Number(left)
+	rightVal, _ := object.Number(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalLogicalExpression short-circuits, right is only evaluated when left
+// does not decide the result on its own.
+func evalLogicalExpression(operator string, left object.Object, right ast.Expression, env *object.Environment) object.Object {
+	if operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	result := Eval(right, env)
+	if isError(result) {
+		return result
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(result))
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	}
+
+	return NULL
+}
+
+// evalSwitchExpression compares the case values with == in order, the
+// default case is only taken when none of them match.
+func evalSwitchExpression(se *ast.SwitchExpression, env *object.Environment) object.Object {
+	var subject object.Object
+	if se.Subject != nil {
+		subject = Eval(se.Subject, env)
+		if isError(subject) {
+			return subject
+		}
+	}
+
+	var defaultCase *ast.SwitchCase
+	for _, sc := range se.Cases {
+		if sc.Values == nil {
+			defaultCase = sc
+			continue
+		}
+
+		for _, v := range sc.Values {
+			value := Eval(v, env)
+			if isError(value) {
+				return value
+			}
+			if subject != nil {
+				value = evalInfixExpression("==", subject, value)
+			}
+
+			if isTruthy(value) {
+				return Eval(sc.Body, env)
+			}
+		}
+	}
+
+	if defaultCase != nil {
+		return Eval(defaultCase.Body, env)
+	}
+
+	return NULL
+}
+
+// evalMatchExpression evaluates the body of the first arm matching the
+// subject, NULL when none does. The names bound by an arm are set like a
+// let would, before its guard is evaluated.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		bound, ok := object.Match(arm.Pattern, subject)
+		if !ok {
+			continue
+		}
+
+		for i, name := range object.Bindings(arm.Pattern) {
+			env.Set(name, bound[i])
+		}
+
+		if guard, ok := arm.Pattern.(*ast.GuardPattern); ok {
+			condition := Eval(guard.Guard, env)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, env)
+	}
+
+	return NULL
+}
+
+// evalLoopBody runs one iteration of a loop, stop is true when the loop
+// ends early because of a break, a return or an error. The result is what
+// the loop statement evaluates to then, nil unless it is passed further up.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, stop bool) {
+	result = Eval(body, env)
+
+	switch {
+	case result == BREAK:
+		return nil, true
+	case result == nil || result == CONTINUE:
+		return nil, false
+	case result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ:
+		return result, true
+	}
+
+	return nil, false
+}
+
+// evalDestructuring sets the names bound by a let pattern, it has no value
+// like any other let.
+func evalDestructuring(pattern ast.Pattern, val object.Object, env *object.Environment) object.Object {
+	bound, err := object.Destructure(pattern, val)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	for i, name := range object.Bindings(pattern) {
+		env.Set(name, bound[i])
+	}
+
+	return nil
+}
+
+// evalWhileStatement and the other loops have no value, like let.
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if result, stop := evalLoopBody(ws.Body, env); stop {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	if fs.Init != nil {
+		if init := Eval(fs.Init, env); isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := Eval(fs.Condition, env)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return nil
+			}
+		}
+
+		if result, stop := evalLoopBody(fs.Body, env); stop {
+			return result
+		}
+
+		if fs.Post != nil {
+			if post := Eval(fs.Post, env); isError(post) {
+				return post
+			}
+		}
+	}
+}
+
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iterator, ok := object.NewIterator(iterable)
+	if !ok {
+		return newError("not iterable: %s", iterable.Type())
+	}
+
+	for item, ok := iterator.Next(); ok; item, ok = iterator.Next() {
+		env.Set(fs.Variable.Value, item)
+
+		if result, stop := evalLoopBody(fs.Body, env); stop {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// evalAssignExpression stores the value in the variable or element the
+// target refers to and evaluates to it. Variables of an enclosing function
+// can not be assigned to, a compiled closure only has a copy of them.
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		scope := env.Scope(target.Value)
+		if scope == nil {
+			return newError("identifier not found: %s", target.Value)
+		}
+		if scope != env && !scope.IsGlobal() {
+			return newError("cannot assign to captured variable %s", target.Value)
+		}
+
+		current, _ := scope.Get(target.Value)
+		value := evalAssignValue(ae, current, env)
+		if isError(value) {
+			return value
+		}
+
+		return scope.Set(target.Value, value)
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if ae.Operator != "=" {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := evalAssignValue(ae, current, env)
+		if isError(value) {
+			return value
+		}
+
+		if err := object.SetIndex(left, index, value); err != nil {
+			return newError("%s", err)
+		}
+
+		return value
+	}
+
+	return newError("cannot assign to %s", ae.Target.String())
+}
+
+// evalAssignValue evaluates the value of an assignment, combined with the
+// current value of the target by a compound one like +=.
+func evalAssignValue(ae *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	value := Eval(ae.Value, env)
+	if isError(value) || ae.Operator == "=" {
+		return value
+	}
+
+	return evalInfixExpression(strings.TrimSuffix(ae.Operator, "="), current, value)
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// evalArrayIndexExpression returns NULL for indexes that are out of range.
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+
+	return arrayObject.Elements[idx]
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
+// evalHashIndexExpression returns NULL for missing keys.
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	val, ok := env.Get(node.Value)
+	if !ok {
+		return newError("identifier not found: %s", node.Value)
+	}
+
+	return val
+}
+
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// evalArguments evaluates the positional arguments of a call, passing the
+// elements of a spread one separately.
+func evalArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
+
+	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if ok {
+			e = spread.Value
+		}
+
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+
+		if !ok {
+			result = append(result, evaluated)
+			continue
+		}
+
+		elements, err := object.Spread(evaluated)
+		if err != nil {
+			return []object.Object{newError("%s", err)}
+		}
+		result = append(result, elements...)
+	}
+
+	return result
+}
+
+func applyFunction(fn object.Object, args []object.Object, names []string, named []object.Object) object.Object {
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError("not a function: %s", fn.Type())
+	}
+
+	extendedEnv, err := extendFunctionEnv(function, args, names, named)
+	if err != nil {
+		return err
+	}
+	evaluated := Eval(function.Body, extendedEnv)
+
+	return unwrapReturnValue(evaluated)
+}
+
+// extendFunctionEnv sets the parameters of fn to the arguments of the call,
+// then evaluates the defaults of the ones left out in order, so a default
+// can use the parameters before it.
+func extendFunctionEnv(fn *object.Function, args []object.Object, names []string,
+	named []object.Object) (*object.Environment, *object.Error) {
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param.Value
+	}
+	// the parameters with a default all come after the required ones
+	required := len(params)
+	for required > 0 && fn.Defaults != nil && fn.Defaults[required-1] != nil {
+		required--
+	}
+
+	bound, err := object.BindArguments(params, required, fn.Rest != nil, args, names, named)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+
+	env := object.NewEnclosedEnvironment(fn.Env)
+	for i, name := range params {
+		if bound[i] != nil {
+			env.Set(name, bound[i])
+		}
+	}
+	if fn.Rest != nil {
+		env.Set(fn.Rest.Value, bound[len(params)])
+	}
+
+	for i, name := range params {
+		if bound[i] != nil {
+			continue
+		}
+
+		value := Eval(fn.Defaults[i], env)
+		if isError(value) {
+			return nil, value.(*object.Error)
+		}
+		env.Set(name, value)
+	}
+
+	return env, nil
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+
+	return obj
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+
+	return false
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,706 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/Gonzih/go-interpreter/lexer"
+	"github.com/Gonzih/go-interpreter/object"
+	"github.com/Gonzih/go-interpreter/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	for _, msg := range p.Errors() {
+		t.Errorf("parser error: %s", msg)
+	}
+	if len(p.Errors()) > 0 {
+		t.FailNow()
+	}
+
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) {
+	result, ok := obj.(*object.Integer)
+	assert.True(t, ok, "object is not Integer, got %T (%+v)", obj, obj)
+	if !ok {
+		t.FailNow()
+	}
+
+	assert.Equal(t, expected, result.Value)
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) {
+	result, ok := obj.(*object.Boolean)
+	assert.True(t, ok, "object is not Boolean, got %T (%+v)", obj, obj)
+	if !ok {
+		t.FailNow()
+	}
+
+	assert.Equal(t, expected, result.Value)
+}
+
+func testNullObject(t *testing.T, obj object.Object) {
+	assert.Equal(t, NULL, obj)
+}
+
+// testExpectedObject checks an int, bool or string result, nil stands for
+// NULL.
+func testExpectedObject(t *testing.T, input string, expected interface{}, obj object.Object) {
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, obj, int64(expected))
+	case bool:
+		testBooleanObject(t, obj, expected)
+	case string:
+		str, ok := obj.(*object.String)
+		assert.True(t, ok, "%s: object is not String, got %T (%+v)", input, obj, obj)
+		if ok {
+			assert.Equal(t, expected, str.Value, input)
+		}
+	case nil:
+		assert.Equal(t, NULL, obj, input)
+	}
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"2 + 10 % 4 * 3", 8},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"7 ** 0", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1", 2.5},
+		{"1 - 0.5", 0.5},
+		{"2.5 * 2", 5},
+		{"1 / 4.0", 0.25},
+		{"5.5 % 2", 1.5},
+		{"2 ** -1.0", 0.5},
+		{"1_000.5 * 2", 2001},
+		{"1e3 + 0x10", 1016},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		float, ok := evaluated.(*object.Float)
+		assert.True(t, ok, "%s: object is not Float, got %T (%+v)", tt.input, evaluated, evaluated)
+		if ok {
+			assert.Equal(t, tt.expected, float.Value, tt.input)
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 < 2", true},
+		{"2.5 >= 2.5", true},
+		{"let n = (0.0 - 1.0) ** 0.5; n >= 1", false},
+		{"let n = (0.0 - 1.0) ** 0.5; n <= 1", false},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 != 0.3", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 2", true},
+		{"1 < 2 && 2 < 3 || false", true},
+		{"false && undefinedName", false},
+		{"true || undefinedName", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if integer, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+
+	testNullObject(t, testEval(t, "let f = fn() { return\n1 }; f()"))
+}
+
+func TestValuelessBlocks(t *testing.T) {
+	testNullObject(t, testEval(t, "fn(){}()"))
+	testNullObject(t, testEval(t, "let g = fn(){ let a = 1 }; g()"))
+	testNullObject(t, testEval(t, "if (true) {}"))
+	testBooleanObject(t, testEval(t, "!fn(){}()"), true)
+
+	arr, ok := testEval(t, "[fn(){}()]").(*object.Array)
+	assert.True(t, ok)
+	if ok {
+		assert.Len(t, arr.Elements, 1)
+		testNullObject(t, arr.Elements[0])
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"10 / 0", "division by zero"},
+		{"10 % 0", "division by zero"},
+		{"1.5 / 0", "division by zero"},
+		{"-true * 1.5", "unknown operator: -BOOLEAN"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"2 ** -1", "negative exponent: -1"},
+		{"true && undefinedName", "identifier not found: undefinedName"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"let [a, b] = [1]; a", "cannot destructure ARRAY with [a, b]"},
+		{"let {a} = 1; a", "cannot destructure INTEGER with {a}"},
+		{"let f = fn(a, b = 1) { a }; f()", "missing argument a"},
+		{"let f = fn(a, b = 1) { a }; f(1, 2, 3)", "wrong number of arguments: want=2, got=3"},
+		{"let f = fn(x) { x }; f(y: 1)", "unknown parameter y"},
+		{"let f = fn(x) { x }; f(1, x: 1)", "argument x is passed more than once"},
+		{"let f = fn(x) { x }; f(...1)", "cannot spread INTEGER"},
+		{"let f = fn(x, y) { x }; f(1, ...2)", "cannot spread INTEGER"},
+		{"1(x: 1)", "not a function: INTEGER"},
+		{"let f = fn(x = y) { x }; f()", "identifier not found: y"},
+		{"5(1)", "not a function: INTEGER"},
+		{"fn(){}() + 1", "type mismatch: NULL + INTEGER"},
+		{"let g = fn(){ let a = 1 }; g() + 1", "type mismatch: NULL + INTEGER"},
+		{"-fn(){}()", "unknown operator: -NULL"},
+		{"let x = if (true) {}; x + 1", "type mismatch: NULL + INTEGER"},
+		{"for x in 5 { x }", "not iterable: INTEGER"},
+		{"x = 1", "identifier not found: x"},
+		{"let f = fn() { let n = 0; fn() { n += 1 } }; f()()", "cannot assign to captured variable n"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{`"abc"[0] = "x"`, "index assignment not supported: STRING"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{`let h = {}; h["k"] += 1`, "type mismatch: NULL + INTEGER"},
+		{"match 1 { n if n + true => n }", "type mismatch: INTEGER + BOOLEAN"},
+		{"while (-true) { }", "unknown operator: -BOOLEAN"},
+		{"for x in [1, 2] { -true; 1 }", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		assert.True(t, ok, "no error object returned for %q, got %T (%+v)", tt.input, evaluated, evaluated)
+		if !ok {
+			continue
+		}
+
+		assert.Equal(t, tt.expectedMessage, errObj.Message)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (true) { if (i == 3) { break; } let i = i + 1; }; i", 3},
+		{"let n = 0; for (; n < 3;) { let n = n + 1; }; n", 3},
+		{"let n = 0; for (let i = 0; n < 3; n) { let n = n + 1; }; n", 3},
+		{"let n = 0; for (;;) { break; let n = 1; }; n", 0},
+		{"let sum = 0; for x in [1, 2, 3] { let sum = sum + x; }; sum", 6},
+		{"let sum = 0; for x in [1, 2, 3, 4] { if (x % 2 == 0) { continue; } let sum = sum + x; }; sum", 4},
+		{"let sum = 0; for x in [1, 2, 3] { for y in [10, 20] { if (y > 10) { break; } let sum = sum + y; } }; sum", 30},
+		{`let s = ""; for c in "abc" { let s = c + s; }; s`, "cba"},
+		{`let s = ""; for k in {"a": 1, "b": 2} { let s = s + k; }; s`, "ab"},
+		{"let f = fn(xs) { for x in xs { if (x > 1) { return x; } } }; f([1, 5, 7])", 5},
+		{"let f = fn(xs) { for x in xs { x } }; f([1])", nil},
+		{"let f = fn() { let i = 0; while (i < 2) { let i = i + 1; } }; f()", nil},
+		{"let f = fn() { let n = 0; for (let i = 0; i < 3; n) { let n = n + 1; let i = n; }; n }; f()", 3},
+		{"let f = fn(xs) { let n = 0; for x in xs { for y in xs { let n = n + x * y; } }; n }; f([1, 2])", 9},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, tt.expected, testEval(t, tt.input))
+	}
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`switch (2) { case 1: "a"; case 2, 3: "b"; default: "c" }`, "b"},
+		{`switch (5) { case 1: "a"; default: "c" }`, "c"},
+		{`switch (5) { default: "c"; case 5: "five" }`, "five"},
+		{`switch (5) { case 1: "a" }`, nil},
+		{"switch (1) { case 1: }", nil},
+		{"switch (1) { }", nil},
+		{`switch (1.0) { case 1: "one" }`, "one"},
+		{`switch ("x") { case 1: 1; case "x": 2 }`, 2},
+		{`let x = 15; switch { case x > 10: "big"; case x > 5: "medium"; default: "small" }`, "big"},
+		{`let x = 7; switch { case x > 10: "big"; case x > 5: "medium"; default: "small" }`, "medium"},
+		{`switch (1) { case 1: "a"; case 1 / 0: "b" }`, "a"},
+		{"let n = 0; for x in [1, 2, 3, 4] { switch (x) { case 3: break; default: n += x } }; n", 3},
+		{"let n = 0; for x in [1, 2, 3] { switch (x) { case 2: continue; } n += x }; n", 4},
+		{`let f = fn(x) { switch (x) { case 1: return "one"; } "other" }; f(1) + f(2)`, "oneother"},
+		{"let f = fn(x) { let y = switch (x) { case 1: 10; default: 20 }; y + 1 }; f(1) + f(2)", 32},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, tt.expected, testEval(t, tt.input))
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match 0 { 0 => "zero", _ => "other" }`, "zero"},
+		{`match 5 { 0 => "zero", _ => "other" }`, "other"},
+		{`match 5 { 0 => "zero" }`, nil},
+		{`match -1 { -1 => "minus one", _ => "other" }`, "minus one"},
+		{`match 1.0 { 1 => "one" }`, "one"},
+		{`match "hi" { "hi" => 1, _ => 2 }`, 1},
+		{"match true { false => 0, true => 1 }", 1},
+		{"match [1, 2, 3] { [x, ...rest] => rest[1] }", 3},
+		{"match [1, 2] { [a] => a, [a, b] => a + b }", 3},
+		{"match [1, 2] { [_, _, _] => 0, [...] => 1 }", 1},
+		{`match [1] { [1] => "one", _ => "other" }`, "one"},
+		{`match {"k": 5, "j": 1} { {"k": v} => v }`, 5},
+		{`match {"name": "x", "age": 3} { {name, ...rest} => rest["age"] }`, 3},
+		{`match {"a": 1} { {"b": v} => v, _ => 0 }`, 0},
+		{`match 15 { n if n > 10 => "big", n => "small" }`, "big"},
+		{`match 5 { n if n > 10 => "big", n => "small" }`, "small"},
+		{`match [[1, 2], {"x": 3}] { [[a, b], {x}] => a + b + x }`, 6},
+		{"let x = 1; match 5 { x => x }; x", 5},
+		{"let f = fn(v) { match v { [a, b] => a * b, n if n > 0 => n, _ => -1 } }; f([2, 3]) + f(4) + f(-5)", 9},
+		{"let n = 0; for x in [1, [2, 3], 4] { n += match x { [a, b] => a * b, _ => x } }; n", 11},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, tt.expected, testEval(t, tt.input))
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x = 2", 2},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 1; x -= 2; x", -1},
+		{"let x = 3; x *= 2; x", 6},
+		{"let x = 7; x /= 2; x", 3},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let a = 0; let b = 0; a = b = 2; a + b", 4},
+		{"let a = [1, 2]; a[0] = 5; a[0]", 5},
+		{"let a = [1, 2]; a[1] += 3; a[1]", 5},
+		{`let h = {}; h["k"] = 1; h["k"] += 1; h["k"]`, 2},
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{"let i = 0; let s = 0; while (i < 4) { i += 1; s += i; }; s", 10},
+		{"let s = 0; for (let i = 0; i < 4; i += 1) { s += i; }; s", 6},
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+		{"let f = fn(x) { x *= 2; x }; f(3)", 6},
+		{"let x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"let f = fn() { f = 5; 1 }; f() + f", 6},
+		{"let a = [[1]]; let f = fn() { a[0][0] += 1 }; f(); f(); a[0][0]", 3},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, tt.expected, testEval(t, tt.input))
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; rest[1]", 3},
+		{"let [_, x] = [1, 2]; x", 2},
+		{`let {name, age} = {"name": "x", "age": 3}; age`, 3},
+		{`let {"k": [x, y]} = {"k": [4, 5]}; x * y`, 20},
+		{`let {a, ...others} = {"a": 1, "b": 2}; others["b"]`, 2},
+		{"let a = 1; let b = 2; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([5, 3])", 2},
+		{"let n = 0; for pair in [[1, 2], [3, 4]] { let [a, b] = pair; n += a * b }; n", 14},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, tt.expected, testEval(t, tt.input))
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(t, input)
+	fn, ok := evaluated.(*object.Function)
+	assert.True(t, ok, "object is not Function, got %T (%+v)", evaluated, evaluated)
+	if !ok {
+		t.FailNow()
+	}
+
+	assert.Len(t, fn.Parameters, 1)
+	assert.Equal(t, "x", fn.Parameters[0].String())
+	assert.Equal(t, "(x + 2)", fn.Body.String())
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a, b = a * 2) { a + b }; f(3)", 9},
+		{"let n = 0; let f = fn(x = n += 1) { x }; f(); f(5); f()", 2},
+		{"fn(a, f = fn() { a }) { f() }(4)", 4},
+		{"let sum = fn(n, acc = 0) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(4)", 10},
+		{"let f = fn(a, ...rest) { match rest { [] => 0, _ => 1 } }; f(1)", 0},
+		{"let f = fn(a, ...rest) { rest[1] }; f(1, 2, 3)", 3},
+		{"let f = fn(a, b) { a - b }; f(b: 1, a: 5)", 4},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c: 5)", 125},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...[1, 2, 3])", 123},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(1, ...[], ...[2], 3)", 123},
+		{"let f = fn(...xs) { let [a, b, c] = xs; c }; f(1, ...[2, 3])", 3},
+		{"let f = fn(a, ...rest) { rest }; f(...[1, 2])[0]", 2},
+		{"let f = fn(a, b = 1) { [a, b] }; f(...[7], b: 8)[1]", 8},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, tt.expected, testEval(t, tt.input))
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(t, input), 4)
+}
+
+func TestRecursion(t *testing.T) {
+	input := `
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2);
+};
+fib(10);`
+
+	testIntegerObject(t, testEval(t, input), 55)
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(t, `"Hello World!"`)
+
+	str, ok := evaluated.(*object.String)
+	assert.True(t, ok, "object is not String, got %T (%+v)", evaluated, evaluated)
+	if ok {
+		assert.Equal(t, "Hello World!", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(t, `let name = "bob"; "Hello" + " " + name + "!"`)
+
+	str, ok := evaluated.(*object.String)
+	assert.True(t, ok, "object is not String, got %T (%+v)", evaluated, evaluated)
+	if ok {
+		assert.Equal(t, "Hello bob!", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	testBooleanObject(t, testEval(t, `"a" == "a"`), true)
+	testBooleanObject(t, testEval(t, `"a" != "a"`), false)
+	testBooleanObject(t, testEval(t, `"a" == "b"`), false)
+
+	evaluated := testEval(t, `"a" - "b"`)
+	errObj, ok := evaluated.(*object.Error)
+	assert.True(t, ok)
+	if ok {
+		assert.Equal(t, "unknown operator: STRING - STRING", errObj.Message)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval(t, "[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	assert.True(t, ok, "object is not Array, got %T (%+v)", evaluated, evaluated)
+	if !ok {
+		t.FailNow()
+	}
+
+	assert.Len(t, result.Elements, 3)
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2 * 3][fn(x){x}(1)]", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if integer, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6,
+}`
+
+	evaluated := testEval(t, input)
+	result, ok := evaluated.(*object.Hash)
+	assert.True(t, ok, "object is not Hash, got %T (%+v)", evaluated, evaluated)
+	if !ok {
+		t.FailNow()
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	assert.Len(t, result.Pairs, len(expected))
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		assert.True(t, ok, "no pair for given key in Pairs")
+		if ok {
+			testIntegerObject(t, pair.Value, expectedValue)
+		}
+	}
+
+	assert.Equal(t, "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}", result.Inspect())
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if integer, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashKeyErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		assert.True(t, ok, "no error object returned, got %T (%+v)", evaluated, evaluated)
+		if ok {
+			assert.Equal(t, tt.expected, errObj.Message)
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/Gonzih/go-interpreter
 
+go 1.16
+
 require github.com/stretchr/testify v1.3.0
@@ -0,0 +1,94 @@
+package lexer
+
+import (
+	"strings"
+
+	"github.com/Gonzih/go-interpreter/token"
+)
+
+// EnableIndentation switches the lexer to indentation mode, it has to be
+// called before the first token is read.
+//
+// In this mode a line indented deeper than the one before it opens a block
+// with an INDENT token when the line before ends with a ":". A line
+// indented less closes the blocks deeper than it with DEDENT tokens, and
+// all blocks are closed at EOF. Other deeper indented lines and lines
+// inside of brackets continue the previous line. Indentation has to use
+// either tabs or spaces, but not both.
+func (l *Lexer) EnableIndentation() {
+	l.indents = []int{0}
+}
+
+// track records the last token scanned and the bracket depth after it.
+func (l *Lexer) track(tok token.Token) {
+	l.last = tok.Type
+	l.lastLine = tok.Pos.Line
+
+	switch tok.Type {
+	case token.LPAREN, token.LBRACKET, token.LBRACE:
+		l.depth++
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		if l.depth > 0 {
+			l.depth--
+		}
+	}
+}
+
+// indentation returns the INDENT or DEDENT tokens that go in front of the
+// token starting at the current char.
+func (l *Lexer) indentation() []token.Token {
+	pos := l.currentPosition()
+
+	var indent string
+	if !l.atEOF {
+		if l.depth > 0 || l.line == l.lastLine {
+			return nil
+		}
+
+		if l.position-l.lineStart != len(l.lineIndent) {
+			// the token follows a comment on its line
+			return nil
+		}
+		indent = string(l.lineIndent)
+		l.checkIndent(indent)
+	}
+
+	width := len(indent)
+	if width > l.indent() && l.last == token.COLON {
+		l.indents = append(l.indents, width)
+		return []token.Token{{Type: token.INDENT, Literal: indent, Pos: pos}}
+	}
+
+	var toks []token.Token
+	for width < l.indent() {
+		l.indents = l.indents[:len(l.indents)-1]
+		toks = append(toks, token.Token{Type: token.DEDENT, Pos: pos})
+	}
+
+	if len(toks) > 0 && width != l.indent() {
+		l.error(pos, "unindent does not match any outer indentation level")
+	}
+
+	return toks
+}
+
+// indent returns the indentation width of the innermost block.
+func (l *Lexer) indent() int {
+	return l.indents[len(l.indents)-1]
+}
+
+// checkIndent reports an indentation that mixes tabs and spaces, either
+// within the line or with the lines indented before.
+func (l *Lexer) checkIndent(indent string) {
+	if indent == "" {
+		return
+	}
+
+	if l.indentChar == 0 {
+		l.indentChar = indent[0]
+	}
+
+	if strings.Trim(indent, string(l.indentChar)) != "" {
+		l.error(l.positionAt(l.lineStart), "indentation mixes tabs and spaces")
+	}
+}
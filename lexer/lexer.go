@@ -1,125 +1,830 @@
 package lexer
 
 import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/Gonzih/go-interpreter/token"
 )
 
+// Error is a lexical error, the lexer reports it and keeps on scanning.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
 type Lexer struct {
-	input string
+	// buf holds the input from offset base on, for a reader it is refilled
+	// from r and everything before mark, the start of the current token or
+	// comment, can be dropped
+	buf  []byte
+	base int
+	mark int
+	r    io.Reader
+	// a read error ends the input, it is reported at the EOF position
+	readErr error
+
 	// current char pos
 	position int
 	// next char pos
 	readPossition int
-	// current char
-	ch byte
+	// current char, utf8.RuneError for invalid UTF-8
+	ch rune
+
+	file *token.File
+	// current line number, offset of its first char and the column of the
+	// current char in runes
+	line      int
+	lineStart int
+	column    int
+	atEOF     bool
+
+	errors []*Error
+
+	// operator symbols added with AddSymbol, words are matched like
+	// keywords and everything else by longest match
+	symbols   map[string]token.TokenType
+	words     map[string]token.TokenType
+	maxSymbol int
+
+	// semiPos is where a semicolon goes if the last token ended its line,
+	// see NextToken; queued holds the tokens read ahead to decide on it
+	semiPos *token.Position
+	queued  []token.Token
+
+	// the type and line of the last token scanned and the bracket depth
+	// after it, used for the indentation mode
+	last     token.TokenType
+	lastLine int
+	depth    int
+
+	// indents is the stack of indentation widths, nil unless indentation
+	// mode is enabled; indentChar is the char used to indent so far
+	indents    []int
+	indentChar byte
+	// the spaces and tabs the current line starts with, collected as they
+	// are read since a reader may have dropped them by the next token
+	lineIndent []byte
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	fset := token.NewFileSet()
+	return NewFile(fset.AddFile("", len(input)), input)
+}
+
+// NewFile creates a lexer for input that records line starts in file, file
+// name is used for token positions. File size should match len(input).
+func NewFile(file *token.File, input string) *Lexer {
+	l := &Lexer{buf: []byte(input), file: file, line: 1}
 	l.readChar()
 
 	return l
 }
 
+// NewReader creates a lexer that reads its input from r as tokens are
+// requested. Only the current token is kept in memory, so the buffer
+// stays around the size of the longest token. The tokens are the same as
+// for New with the whole input.
+func NewReader(r io.Reader) *Lexer {
+	fset := token.NewFileSet()
+	return NewReaderFile(fset.AddFile("", 0), r)
+}
+
+// NewReaderFile is NewReader recording line starts in file, which grows
+// as the input is read. File has to be empty and the last file of its set.
+func NewReaderFile(file *token.File, r io.Reader) *Lexer {
+	l := &Lexer{r: r, file: file, line: 1}
+	l.readChar()
+
+	return l
+}
+
+const chunkSize = 4096
+
+// fill reads from the reader until the buffer holds the input up to offset
+// end, or until the input is exhausted.
+func (l *Lexer) fill(end int) {
+	for l.r != nil && l.base+len(l.buf) < end {
+		if len(l.buf)+chunkSize > cap(l.buf) {
+			// make room by dropping what is before mark first
+			if n := l.mark - l.base; n > 0 {
+				l.buf = append(l.buf[:0], l.buf[n:]...)
+				l.base = l.mark
+			}
+
+			if len(l.buf)+chunkSize > cap(l.buf) {
+				buf := make([]byte, len(l.buf), 2*cap(l.buf)+chunkSize)
+				copy(buf, l.buf)
+				l.buf = buf
+			}
+		}
+
+		n, err := l.r.Read(l.buf[len(l.buf):cap(l.buf)])
+		l.buf = l.buf[:len(l.buf)+n]
+		l.file.SetSize(l.base + len(l.buf))
+
+		if err != nil {
+			if err != io.EOF {
+				l.readErr = err
+			}
+			l.r = nil
+		}
+	}
+}
+
+// has reports whether offset is inside of the input, reading up to it.
+func (l *Lexer) has(offset int) bool {
+	l.fill(offset + 1)
+	return offset < l.base+len(l.buf)
+}
+
+// text returns the input between two offsets, from must not be before the
+// current mark.
+func (l *Lexer) text(from, to int) string {
+	return string(l.buf[from-l.base : to-l.base])
+}
+
+// File returns the file the lexer reports positions for.
+func (l *Lexer) File() *token.File {
+	return l.file
+}
+
+// Errors returns the lexical errors found so far, in source order.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// SymbolType returns the token type an operator symbol is scanned as once
+// it is added with AddSymbol, without adding it. A symbol is either a word,
+// like "mod", or a run of punctuation, like "|>", that does not start a
+// comment. Symbols that are already tokens keep their builtin type, new
+// ones use the symbol itself as the type.
+func SymbolType(symbol string) (token.TokenType, error) {
+	if symbol == "" {
+		return "", fmt.Errorf("empty operator symbol")
+	}
+
+	if token.IsIdentifier(symbol) {
+		tt := token.LookupIdent(symbol)
+		if tt == token.IDENT {
+			tt = token.TokenType(symbol)
+		}
+
+		return tt, nil
+	}
+
+	for i := 0; i < len(symbol); i++ {
+		if !isSymbolChar(symbol[i]) {
+			return "", fmt.Errorf("invalid operator symbol %q", symbol)
+		}
+	}
+
+	// it would be read as a comment before it could be scanned
+	if strings.HasPrefix(symbol, "//") || strings.HasPrefix(symbol, "/*") {
+		return "", fmt.Errorf("operator symbol %q starts a comment", symbol)
+	}
+
+	if builtin, ok := symbolTokenTable[symbol]; ok {
+		return builtin, nil
+	}
+	if builtin, ok := tokenTable[rune(symbol[0])]; ok && len(symbol) == 1 {
+		return builtin, nil
+	}
+
+	return token.TokenType(symbol), nil
+}
+
+// AddSymbol makes the lexer recognize an extra operator symbol and returns
+// the token type it is scanned as, see SymbolType.
+func (l *Lexer) AddSymbol(symbol string) (token.TokenType, error) {
+	tt, err := SymbolType(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	if token.IsIdentifier(symbol) {
+		if l.words == nil {
+			l.words = make(map[string]token.TokenType)
+		}
+		l.words[symbol] = tt
+
+		return tt, nil
+	}
+
+	if l.symbols == nil {
+		l.symbols = make(map[string]token.TokenType)
+	}
+	l.symbols[symbol] = tt
+	if len(symbol) > l.maxSymbol {
+		l.maxSymbol = len(symbol)
+	}
+
+	return tt, nil
+}
+
+func (l *Lexer) error(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
 func (l *Lexer) readChar() {
-	if l.readPossition >= len(l.input) {
+	// read ahead first, the file only takes line starts inside of its size
+	more := l.has(l.readPossition)
+
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPossition
+		l.column = 0
+		l.file.AddLine(l.lineStart)
+		l.lineIndent = l.lineIndent[:0]
+	}
+
+	if !more {
+		// stay at EOF so that repeated reads keep a stable position
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPossition]
+		l.position = l.readPossition
+		if !l.atEOF {
+			l.atEOF = true
+			l.column++
+			if l.readErr != nil {
+				l.error(l.currentPosition(), "read error: %v", l.readErr)
+			}
+		}
+		return
+	}
+
+	ch, size := rune(l.buf[l.readPossition-l.base]), 1
+	if ch >= utf8.RuneSelf {
+		l.fill(l.readPossition + utf8.UTFMax)
+		ch, size = utf8.DecodeRune(l.buf[l.readPossition-l.base:])
+		l.file.AddRune(l.readPossition, size)
 	}
+
+	l.ch = ch
 	l.position = l.readPossition
-	l.readPossition++
+	l.readPossition += size
+	l.column++
+
+	if (ch == ' ' || ch == '\t') && l.position-l.lineStart == len(l.lineIndent) {
+		l.lineIndent = append(l.lineIndent, byte(ch))
+	}
+
+	if ch == utf8.RuneError && size == 1 {
+		l.error(l.currentPosition(), "invalid UTF-8 encoding (byte %#x)", l.buf[l.position-l.base])
+	}
 }
 
-var tokenTable = map[byte]token.TokenType{
+var tokenTable = map[rune]token.TokenType{
 	'=': token.ASSIGN,
 	';': token.SEMICOLON,
 	'(': token.LPAREN,
 	')': token.RPAREN,
 	'{': token.LBRACE,
 	'}': token.RBRACE,
+	'[': token.LBRACKET,
+	']': token.RBRACKET,
 	'+': token.PLUS,
 	'-': token.MINUS,
 	'!': token.BANG,
 	'/': token.SLASH,
 	'*': token.ASTERISK,
+	'%': token.PERCENT,
 	'<': token.LT,
 	'>': token.GT,
 	',': token.COMMA,
+	':': token.COLON,
 	0:   token.EOF,
 }
 
+// symbolTokenTable holds the tokens made of several chars, they take
+// priority over the single char tokens they start with.
+var symbolTokenTable = map[string]token.TokenType{
+	"==":  token.EQ,
+	"!=":  token.NOT_EQ,
+	"<=":  token.LT_EQ,
+	">=":  token.GT_EQ,
+	"&&":  token.AND,
+	"||":  token.OR,
+	"**":  token.POWER,
+	"+=":  token.PLUS_ASSIGN,
+	"-=":  token.MINUS_ASSIGN,
+	"*=":  token.ASTERISK_ASSIGN,
+	"/=":  token.SLASH_ASSIGN,
+	"=>":  token.ARROW,
+	"...": token.ELLIPSIS,
+}
+
+// endsStatement holds the tokens that end a statement when they are the
+// last token of a line.
+var endsStatement = map[token.TokenType]bool{
+	token.IDENT:    true,
+	token.INT:      true,
+	token.FLOAT:    true,
+	token.STRING:   true,
+	token.TRUE:     true,
+	token.FALSE:    true,
+	token.RETURN:   true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.RPAREN:   true,
+	token.RBRACKET: true,
+	token.RBRACE:   true,
+}
+
+// continuesLine holds the tokens that keep the previous line going, no
+// semicolon is inserted in front of them. This allows closing delimiters
+// of multi-line literals, "} else" and braces on their own line.
+var continuesLine = map[token.TokenType]bool{
+	token.RPAREN:   true,
+	token.RBRACKET: true,
+	token.RBRACE:   true,
+	token.LBRACE:   true,
+	token.ELSE:     true,
+	token.INDENT:   true,
+	token.DEDENT:   true,
+}
+
+// NextToken returns the next token along with the comments around it.
+//
+// Like in Go a newline after a token of endsStatement inserts a SEMICOLON
+// token with the literal "\n", so that a line like "let x = a" is not
+// continued by a next line starting with "(". The semicolon is left out
+// when the next token is in continuesLine, and it is not inserted at EOF.
+// In indentation mode INDENT and DEDENT tokens are inserted as well, see
+// EnableIndentation.
 func (l *Lexer) NextToken() token.Token {
+	if len(l.queued) == 0 {
+		l.queued = l.next()
+	}
+
+	tok := l.queued[0]
+	l.queued = l.queued[1:]
+
+	return tok
+}
+
+// next scans a token and returns it after the SEMICOLON, INDENT and DEDENT
+// tokens that go in front of it.
+func (l *Lexer) next() []token.Token {
+	semiPos := l.semiPos
+	l.semiPos = nil
+
+	leading := l.readComments(true)
+
+	var toks []token.Token
+	if l.indents != nil {
+		toks = l.indentation()
+	}
+
+	tok := l.scan()
+	tok.Leading = leading
+	tok.Trailing = l.readComments(false)
+
+	if endsStatement[tok.Type] {
+		l.semiPos = l.lineEnd(tok.Trailing)
+	}
+	l.track(tok)
+
+	// a dedent ends the statement before it like a "}" does
+	if n := len(toks); n > 0 && toks[n-1].Type == token.DEDENT &&
+		tok.Type != token.EOF && !continuesLine[tok.Type] {
+		toks = append(toks, token.Token{Type: token.SEMICOLON, Literal: "\n", Pos: tok.Pos})
+	}
+	toks = append(toks, tok)
+
+	if semiPos != nil && toks[0].Type != token.EOF && !continuesLine[toks[0].Type] {
+		semi := token.Token{Type: token.SEMICOLON, Literal: "\n", Pos: *semiPos}
+		toks = append([]token.Token{semi}, toks...)
+	}
+
+	return toks
+}
+
+// lineEnd returns the position of the newline ending the current line
+// when only trailing comments are left on it, a comment spanning several
+// lines counts as a newline.
+func (l *Lexer) lineEnd(trailing []token.Comment) *token.Position {
+	for _, c := range trailing {
+		if strings.Contains(c.Text, "\n") {
+			pos := c.Pos
+			return &pos
+		}
+	}
+
+	if l.ch == '\n' {
+		pos := l.currentPosition()
+		return &pos
+	}
+
+	return nil
+}
+
+func (l *Lexer) scan() token.Token {
 	var tok token.Token
 
-	l.skipWhitespaces()
+	l.mark = l.position
+	tok.Pos = l.currentPosition()
 
-	// handling != and ==
-	if (l.ch == '=' || l.ch == '!') && l.peekChar() == '=' {
-		ch := l.ch
-		l.readChar()
-		tok.Literal = string(ch) + string(l.ch)
-		tok.Type = token.LookupIdent(tok.Literal)
+	if tt, n := l.matchSymbol(); n > 0 {
+		tok.Literal = l.text(l.position, l.position+n)
+		tok.Type = tt
+		for i := 1; i < n; i++ {
+			l.readChar()
+		}
 	} else if tt, ok := tokenTable[l.ch]; ok {
-		tok = newToken(tt, l.ch)
+		tok = newToken(tt, l.ch, tok.Pos)
 	} else if isLetter(l.ch) {
 		tok.Literal = l.readIdentifier()
-		tok.Type = token.LookupIdent(tok.Literal)
+		tok.Type = l.lookupIdent(tok.Literal)
 		return tok
 	} else if isDigit(l.ch) {
-		tok.Type = token.INT
-		tok.Literal = l.readNumber()
+		tok.Type, tok.Literal = l.readNumber()
+		return tok
+	} else if l.ch == '"' {
+		tok.Type = token.STRING
+		tok.Literal = l.readString()
 		return tok
 	} else {
-		tok = newToken(token.ILLEGAL, l.ch)
+		tok.Type = token.ILLEGAL
+		tok.Literal = l.text(l.position, l.readPossition)
 	}
 
 	l.readChar()
 	return tok
 }
 
+// matchSymbol finds the longest operator of two chars or more, or an added
+// symbol of any length, starting at the current char. It returns the length
+// of the match, 0 when there is none.
+func (l *Lexer) matchSymbol() (token.TokenType, int) {
+	// "..." is the longest builtin symbol
+	n := l.maxSymbol
+	if n < 3 {
+		n = 3
+	}
+
+	for ; n >= 1; n-- {
+		if !l.has(l.position + n - 1) {
+			continue
+		}
+
+		s := l.buf[l.position-l.base : l.position-l.base+n]
+		if tt, ok := l.symbols[string(s)]; ok {
+			return tt, n
+		}
+		if tt, ok := symbolTokenTable[string(s)]; ok {
+			return tt, n
+		}
+	}
+
+	return "", 0
+}
+
+func (l *Lexer) lookupIdent(ident string) token.TokenType {
+	if tt, ok := l.words[ident]; ok {
+		return tt
+	}
+
+	return token.LookupIdent(ident)
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.file.Name(),
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// readIdentifier reads an identifier as defined by token.IsIdentifier. A
+// trailing "?" or "!" is left alone when it is followed by "=", so that
+// a!=b still is a != b, or when it was added with AddSymbol, so that a
+// postfix n! is n followed by the operator.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	_, added := l.symbols[string(l.ch)]
+	if (l.ch == '?' || l.ch == '!') && l.peekChar() != '=' && !added {
+		l.readChar()
+	}
+
+	return l.text(position, l.position)
+}
+
+var radixes = map[rune]struct {
+	base int
+	name string
+}{
+	'x': {16, "hexadecimal"},
+	'X': {16, "hexadecimal"},
+	'o': {8, "octal"},
+	'O': {8, "octal"},
+	'b': {2, "binary"},
+	'B': {2, "binary"},
+}
+
+// readNumber reads an integer, optionally prefixed with 0x, 0o or 0b, or a
+// decimal float with a fraction and/or an exponent. Digits can be separated
+// by single underscores. A float needs a digit before the dot, so .5 is not
+// a number and 1..2 is the integer 1 followed by "..".
+func (l *Lexer) readNumber() (token.TokenType, string) {
+	start := l.currentPosition()
+	position := l.position
+
+	if radix, ok := radixes[l.peekChar()]; ok && l.ch == '0' {
+		l.readChar()
+		l.readChar()
+
+		// consume anything that looks like a digit to report it as a whole
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+
+		lit := l.text(position, l.position)
+		if !strings.ContainsAny(lit[2:], "0123456789abcdefABCDEF") {
+			l.error(start, "%s literal has no digits", radix.name)
+			return token.INT, lit
+		}
+
+		for i := 2; i < len(lit); i++ {
+			if ch := rune(lit[i]); ch != '_' && digitValue(ch) >= radix.base {
+				l.error(l.positionAt(position+i), "invalid digit %q in %s literal", ch, radix.name)
+				return token.INT, lit
+			}
+		}
+
+		l.checkUnderscores(start, lit, isHexDigit)
+		return token.INT, lit
+	}
+
+	tt := token.TokenType(token.INT)
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tt = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		tt = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+
+		if !isDigit(l.ch) {
+			l.error(start, "exponent has no digits")
+			return tt, l.text(position, l.position)
+		}
+		l.readDigits()
+	}
+
+	lit := l.text(position, l.position)
+	if tt == token.INT && len(lit) > 1 && lit[0] == '0' {
+		l.error(start, "invalid leading zero in %s, use the 0o prefix for octal", lit)
+		return tt, lit
+	}
+
+	l.checkUnderscores(start, lit, isDigit)
+	return tt, lit
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// checkUnderscores reports underscores that do not sit between two digits,
+// the radix prefix counts as a digit so 0x_ff is fine.
+func (l *Lexer) checkUnderscores(pos token.Position, lit string, digit func(rune) bool) {
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '_' {
+			continue
+		}
+
+		after := i+1 < len(lit) && digit(rune(lit[i+1]))
+		before := i > 0 && digit(rune(lit[i-1])) || i == 2 && lit[0] == '0'
+		if !before || !after {
+			l.error(pos, "'_' must separate successive digits in %s", lit)
+			return
+		}
+	}
+}
+
+// positionAt returns the position of offset, which must be on the current
+// line.
+func (l *Lexer) positionAt(offset int) token.Position {
+	pos := l.currentPosition()
+	pos.Column += offset - pos.Offset
+	pos.Offset = offset
+
+	return pos
+}
+
+// readString reads a double quoted string starting at the current quote and
+// returns its unescaped value. Strings can not span several lines.
+func (l *Lexer) readString() string {
+	var out strings.Builder
+
+	start := l.currentPosition()
+	l.readChar()
+
+	for {
+		switch l.ch {
+		case '"':
+			l.readChar()
+			return out.String()
+		case '\n', 0:
+			l.error(start, "string literal not terminated")
+			return out.String()
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+	}
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'"':  '"',
+	'\\': '\\',
 }
 
-func (l *Lexer) readNumber() string {
+// readEscape reads an escape sequence starting at the current backslash
+// and writes the character it stands for to out.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.currentPosition()
+	l.readChar()
+
+	if ch, ok := escapes[l.ch]; ok {
+		out.WriteRune(ch)
+		l.readChar()
+		return
+	}
+
+	if l.ch != 'u' {
+		if l.ch == '\n' || l.ch == 0 {
+			l.error(pos, "escape sequence not terminated")
+		} else {
+			l.error(pos, "unknown escape sequence \\%c", l.ch)
+			l.readChar()
+		}
+		return
+	}
+
+	l.readChar()
+	if l.ch != '{' {
+		l.error(pos, "expected { after \\u")
+		return
+	}
+	l.readChar()
+
 	position := l.position
-	for isDigit(l.ch) {
+	for isHexDigit(l.ch) {
 		l.readChar()
 	}
+	digits := l.text(position, l.position)
 
-	return l.input[position:l.position]
+	if l.ch != '}' {
+		l.error(pos, "unicode escape sequence not terminated")
+		return
+	}
+	l.readChar()
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if len(digits) == 0 || len(digits) > 6 || err != nil || !utf8.ValidRune(rune(code)) {
+		l.error(pos, "invalid unicode code point \\u{%s}", digits)
+		return
+	}
+
+	out.WriteRune(rune(code))
+}
+
+// readComments skips whitespace and returns the comments found in it.
+// Unless newlines is set it stops at the end of the current line, which
+// gives the comments trailing a token.
+func (l *Lexer) readComments(newlines bool) []token.Comment {
+	var comments []token.Comment
+
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '\n' && newlines:
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/',
+			l.ch == '#' && l.peekChar() == '!' && l.position == 0:
+			comments = append(comments, l.readLineComment())
+		case l.ch == '/' && l.peekChar() == '*':
+			comments = append(comments, l.readBlockComment())
+		default:
+			return comments
+		}
+	}
 }
 
-func (l *Lexer) skipWhitespaces() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// readLineComment reads a comment up to the end of the line, the newline is
+// not part of it.
+func (l *Lexer) readLineComment() token.Comment {
+	comment := token.Comment{Pos: l.currentPosition()}
+
+	l.mark = l.position
+	for l.ch != '\n' && !l.atEOF {
 		l.readChar()
 	}
+	comment.Text = strings.TrimSuffix(l.text(l.mark, l.position), "\r")
+
+	return comment
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPossition >= len(l.input) {
+// readBlockComment reads a /* */ comment, block comments nest.
+func (l *Lexer) readBlockComment() token.Comment {
+	comment := token.Comment{Pos: l.currentPosition()}
+
+	l.mark = l.position
+	depth := 0
+	for {
+		if l.atEOF {
+			l.error(comment.Pos, "comment not terminated")
+			break
+		}
+
+		if l.ch == '/' && l.peekChar() == '*' {
+			depth++
+			l.readChar()
+		} else if l.ch == '*' && l.peekChar() == '/' {
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+
+		if depth == 0 {
+			break
+		}
+	}
+	comment.Text = l.text(l.mark, l.position)
+
+	return comment
+}
+
+func (l *Lexer) peekChar() rune {
+	if !l.has(l.readPossition) {
 		return 0
 	}
 
-	return l.input[l.readPossition]
+	l.fill(l.readPossition + utf8.UTFMax)
+	ch, _ := utf8.DecodeRune(l.buf[l.readPossition-l.base:])
+	return ch
+}
+
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch == '?'
+// isSymbolChar reports whether ch can be part of an operator symbol.
+func isSymbolChar(ch byte) bool {
+	return strings.IndexByte("!#$%&*+-./<=>?@^|~:", ch) >= 0
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
-	tok := token.Token{Type: tokenType, Literal: string(ch)}
+func digitValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch - 'a' + 10)
+	case 'A' <= ch && ch <= 'F':
+		return int(ch - 'A' + 10)
+	}
+
+	return 16
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func newToken(tokenType token.TokenType, ch rune, pos token.Position) token.Token {
+	tok := token.Token{Type: tokenType, Literal: string(ch), Pos: pos}
 	if tokenType == token.EOF {
 		tok.Literal = ""
 	}
@@ -2,7 +2,10 @@ package lexer
 
 import (
 	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/Gonzih/go-interpreter/token"
 	"github.com/stretchr/testify/assert"
@@ -17,7 +20,7 @@ let add = fn(x, y) {
 }
 
 let result = add(five, ten)
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -27,7 +30,14 @@ if (5 < 10) {
 }
 
 10 == 10;
-10 != 9;`
+10 != 9;
+[1, 2];
+{"foo": "bar"}
+while for in break continue
+x += 1 -= 2 *= 3 /= 4 = 5
+<= >= && || % < >
+2 ** 3 * 4
+match => ... ..`
 
 	tests := []struct {
 		expectedType    token.TokenType
@@ -58,6 +68,7 @@ if (5 < 10) {
 		{token.IDENT, "y"},
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
+		{token.SEMICOLON, "\n"},
 		{token.LET, "let"},
 		{token.IDENT, "result"},
 		{token.ASSIGN, "="},
@@ -67,6 +78,7 @@ if (5 < 10) {
 		{token.COMMA, ","},
 		{token.IDENT, "ten"},
 		{token.RPAREN, ")"},
+		{token.SEMICOLON, "\n"},
 		{token.BANG, "!"},
 		{token.MINUS, "-"},
 		{token.SLASH, "/"},
@@ -96,6 +108,7 @@ if (5 < 10) {
 		{token.FALSE, "false"},
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
+		{token.SEMICOLON, "\n"},
 
 		{token.INT, "10"},
 		{token.EQ, "=="},
@@ -107,6 +120,61 @@ if (5 < 10) {
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
 
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, "\n"},
+
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, "\n"},
+
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, "\n"},
+
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.PERCENT, "%"},
+		{token.LT, "<"},
+		{token.GT, ">"},
+
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.ASTERISK, "*"},
+		{token.INT, "4"},
+		{token.SEMICOLON, "\n"},
+
+		{token.MATCH, "match"},
+		{token.ARROW, "=>"},
+		{token.ELLIPSIS, "..."},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+
 		{token.EOF, ""},
 	}
 
@@ -120,3 +188,705 @@ if (5 < 10) {
 		assert.Equal(t, tt.expectedLiteral, tok.Literal, fmsg)
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n\tx + 10\n\n==\n"
+
+	tests := []struct {
+		expectedType token.TokenType
+		line         int
+		column       int
+		offset       int
+	}{
+		{token.LET, 1, 1, 0},
+		{token.IDENT, 1, 5, 4},
+		{token.ASSIGN, 1, 7, 6},
+		{token.INT, 1, 9, 8},
+		{token.SEMICOLON, 1, 10, 9},
+		{token.IDENT, 2, 2, 12},
+		{token.PLUS, 2, 4, 14},
+		{token.INT, 2, 6, 16},
+		{token.SEMICOLON, 2, 8, 18},
+		{token.EQ, 4, 1, 20},
+		{token.EOF, 5, 1, 23},
+		{token.EOF, 5, 1, 23},
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("test.mk", len(input))
+	l := NewFile(file, input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+
+		fmsg := fmt.Sprintf("%#v != %#v", tt, tok)
+		assert.Equal(t, tt.expectedType, tok.Type, fmsg)
+		assert.Equal(t, "test.mk", tok.Pos.Filename, fmsg)
+		assert.Equal(t, tt.line, tok.Pos.Line, fmsg)
+		assert.Equal(t, tt.column, tok.Pos.Column, fmsg)
+		assert.Equal(t, tt.offset, tok.Pos.Offset, fmsg)
+
+		// the line table recorded in the file agrees with the lexer
+		if tt.offset < len(input) {
+			assert.Equal(t, tok.Pos, fset.Position(file.Pos(tt.offset)), fmsg)
+		}
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+	}{
+		{`"foobar"`, "foobar"},
+		{`"foo bar"`, "foo bar"},
+		{`""`, ""},
+		{`"a\nb\tc"`, "a\nb\tc"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{41}\u{e9}\u{1F600}"`, "Aé😀"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, token.TokenType(token.STRING), tok.Type, tt.input)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal, tt.input)
+		assert.Equal(t, token.TokenType(token.EOF), l.NextToken().Type, tt.input)
+		assert.Empty(t, l.Errors(), tt.input)
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+		next            token.TokenType
+	}{
+		{`"abc`, "abc", "1:1: string literal not terminated", token.EOF},
+		{"\"abc\nlet", "abc", "1:1: string literal not terminated", token.SEMICOLON},
+		{`"a\qb"`, "ab", `1:3: unknown escape sequence \q`, token.EOF},
+		{`"a\u41"`, "a41", `1:3: expected { after \u`, token.EOF},
+		{`"\u{41"`, "", "1:2: unicode escape sequence not terminated", token.EOF},
+		{`"\u{}"`, "", `1:2: invalid unicode code point \u{}`, token.EOF},
+		{`"\u{D800}"`, "", `1:2: invalid unicode code point \u{D800}`, token.EOF},
+		{`"\u{1234567}"`, "", `1:2: invalid unicode code point \u{1234567}`, token.EOF},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, token.TokenType(token.STRING), tok.Type, tt.input)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal, tt.input)
+		assert.Equal(t, tt.next, l.NextToken().Type, tt.input)
+
+		assert.Len(t, l.Errors(), 1, tt.input)
+		if len(l.Errors()) == 1 {
+			assert.Equal(t, tt.expectedError, l.Errors()[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestAddSymbol(t *testing.T) {
+	input := `a |> f .. <=> <= mod b ... * ==`
+
+	l := New(input)
+	for _, symbol := range []string{"|>", "..", "...", "<=>", "mod", "*"} {
+		_, err := l.AddSymbol(symbol)
+		assert.NoError(t, err, symbol)
+	}
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{"|>", "|>"},
+		{token.IDENT, "f"},
+		{"..", ".."},
+		{"<=>", "<=>"},
+		{token.LT_EQ, "<="},
+		{"mod", "mod"},
+		{token.IDENT, "b"},
+		{"...", "..."},
+		{token.ASTERISK, "*"},
+		{token.EQ, "=="},
+		{token.EOF, ""},
+	}
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+
+	tt, err := l.AddSymbol("in")
+	assert.NoError(t, err)
+	assert.Equal(t, token.TokenType(token.IN), tt)
+
+	for _, symbol := range []string{"", "a+", "|1", "\"", "( ", "//", "/*", "/**/"} {
+		_, err := l.AddSymbol(symbol)
+		assert.Error(t, err, symbol)
+	}
+}
+
+func TestAddSymbolIdentifierSuffix(t *testing.T) {
+	l := New(`n! empty? x? ?? x?= y`)
+	_, err := l.AddSymbol("!")
+	assert.NoError(t, err)
+	_, err = l.AddSymbol("?")
+	assert.NoError(t, err)
+	_, err = l.AddSymbol("??")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "n"},
+		{token.BANG, "!"},
+		{token.IDENT, "empty"},
+		{"?", "?"},
+		{token.IDENT, "x"},
+		{"?", "?"},
+		{"??", "??"},
+		{token.IDENT, "x"},
+		{"?", "?"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "y"},
+		{token.EOF, ""},
+	}
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type, tt.expectedLiteral)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"0", token.INT, "0"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"0x1F", token.INT, "0x1F"},
+		{"0XdEaD_bEeF", token.INT, "0XdEaD_bEeF"},
+		{"0o17", token.INT, "0o17"},
+		{"0b1010_1010", token.INT, "0b1010_1010"},
+		{"0x_ff", token.INT, "0x_ff"},
+		{"3.14", token.FLOAT, "3.14"},
+		{"0.5", token.FLOAT, "0.5"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"6.02E+23", token.FLOAT, "6.02E+23"},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1"},
+		{"1..10", token.INT, "1"},
+		{"1.foo", token.INT, "1"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, tt.expectedType, tok.Type, tt.input)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal, tt.input)
+		assert.Empty(t, l.Errors(), tt.input)
+	}
+
+	// a leading dot is not part of a number
+	l := New(".5")
+	assert.Equal(t, token.TokenType(token.ILLEGAL), l.NextToken().Type)
+	assert.Equal(t, token.TokenType(token.INT), l.NextToken().Type)
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"0x", "0x", "1:1: hexadecimal literal has no digits"},
+		{"0b_", "0b_", "1:1: binary literal has no digits"},
+		{"0o78", "0o78", "1:4: invalid digit '8' in octal literal"},
+		{"0b102", "0b102", "1:5: invalid digit '2' in binary literal"},
+		{"1e", "1e", "1:1: exponent has no digits"},
+		{"2.5e+", "2.5e+", "1:1: exponent has no digits"},
+		{"1__0", "1__0", "1:1: '_' must separate successive digits in 1__0"},
+		{"1_", "1_", "1:1: '_' must separate successive digits in 1_"},
+		{"1_.5", "1_.5", "1:1: '_' must separate successive digits in 1_.5"},
+		{"0x1_", "0x1_", "1:1: '_' must separate successive digits in 0x1_"},
+		{"012", "012", "1:1: invalid leading zero in 012, use the 0o prefix for octal"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		assert.Equal(t, tt.expectedLiteral, tok.Literal, tt.input)
+		assert.Equal(t, token.TokenType(token.EOF), l.NextToken().Type, tt.input)
+
+		assert.Len(t, l.Errors(), 1, tt.input)
+		if len(l.Errors()) == 1 {
+			assert.Equal(t, tt.expectedError, l.Errors()[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let café = \"naïve ✓\";\nπ2 + 日本語 ≠"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		line            int
+		column          int
+		offset          int
+	}{
+		{token.LET, "let", 1, 1, 0},
+		{token.IDENT, "café", 1, 5, 4},
+		{token.ASSIGN, "=", 1, 10, 10},
+		{token.STRING, "naïve ✓", 1, 12, 12},
+		{token.SEMICOLON, ";", 1, 21, 24},
+		{token.IDENT, "π2", 2, 1, 26},
+		{token.PLUS, "+", 2, 4, 30},
+		{token.IDENT, "日本語", 2, 6, 32},
+		{token.ILLEGAL, "≠", 2, 10, 42},
+		{token.EOF, "", 2, 11, 45},
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", len(input))
+	l := NewFile(file, input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+
+		fmsg := fmt.Sprintf("%#v != %#v", tt, tok)
+		assert.Equal(t, tt.expectedType, tok.Type, fmsg)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal, fmsg)
+		assert.Equal(t, tt.line, tok.Pos.Line, fmsg)
+		assert.Equal(t, tt.column, tok.Pos.Column, fmsg)
+		assert.Equal(t, tt.offset, tok.Pos.Offset, fmsg)
+		// the file set counts columns the same way
+		assert.Equal(t, tok.Pos, fset.Position(file.Pos(tt.offset)), fmsg)
+	}
+
+	assert.Empty(t, l.Errors())
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+		types         []token.TokenType
+	}{
+		{"é\xff", "1:2: invalid UTF-8 encoding (byte 0xff)", []token.TokenType{token.IDENT, token.ILLEGAL, token.EOF}},
+		{"x = \xc3;", "1:5: invalid UTF-8 encoding (byte 0xc3)", []token.TokenType{token.IDENT, token.ASSIGN, token.ILLEGAL, token.SEMICOLON}},
+		{"\"a\xe2\x9c\"", "1:3: invalid UTF-8 encoding (byte 0xe2)", []token.TokenType{token.STRING, token.EOF}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for _, expected := range tt.types {
+			assert.Equal(t, expected, l.NextToken().Type, tt.input)
+		}
+
+		if assert.NotEmpty(t, l.Errors(), tt.input) {
+			assert.Equal(t, tt.expectedError, l.Errors()[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	input := `x1 add2 user_id_2 _tmp empty? push! a!=b c?==d e?? 2x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x1"},
+		{token.IDENT, "add2"},
+		{token.IDENT, "user_id_2"},
+		{token.IDENT, "_tmp"},
+		{token.IDENT, "empty?"},
+		{token.IDENT, "push!"},
+		{token.IDENT, "a"},
+		{token.NOT_EQ, "!="},
+		{token.IDENT, "b"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "?"},
+		{token.EQ, "=="},
+		{token.IDENT, "d"},
+		{token.IDENT, "e?"},
+		{token.ILLEGAL, "?"},
+		{token.INT, "2"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tt.expectedType, tok.Type, tt.expectedLiteral)
+		assert.Equal(t, tt.expectedLiteral, tok.Literal)
+	}
+}
+
+func TestSemicolonInsertion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x\ny", []string{"x", "\n", "y"}},
+		{"x;\ny", []string{"x", ";", "y"}},
+		{"x +\ny", []string{"x", "+", "y"}},
+		{"x\n", []string{"x"}},
+		{"1\n2.5\n\"s\"\ntrue\nfalse\n", []string{"1", "\n", "2.5", "\n", "s", "\n", "true", "\n", "false"}},
+		{"return\nbreak\ncontinue\nx", []string{"return", "\n", "break", "\n", "continue", "\n", "x"}},
+		{"f()\na[0]\n{}\nx", []string{"f", "(", ")", "\n", "a", "[", "0", "]", "{", "}", "\n", "x"}},
+		{"f(\n1\n)", []string{"f", "(", "1", ")"}},
+		{"[1\n]", []string{"[", "1", "]"}},
+		{"} \n else", []string{"}", "else"}},
+		{"fn()\n{ x\n}", []string{"fn", "(", ")", "{", "x", "}"}},
+		{"x // note\ny", []string{"x", "\n", "y"}},
+		{"x /* a\nb */ y", []string{"x", "\n", "y"}},
+		{"x /* a */ y", []string{"x", "y"}},
+		{"x\r\n\n\ny", []string{"x", "\n", "y"}},
+	}
+
+	for _, tt := range tests {
+		var literals []string
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			literals = append(literals, tok.Literal)
+		}
+
+		assert.Equal(t, tt.expected, literals, tt.input)
+		assert.Empty(t, l.Errors(), tt.input)
+	}
+
+	// the inserted semicolon is placed at the newline or the comment
+	// spanning it
+	l := New("x // a\ny /* b\n */ z")
+	l.NextToken()
+	semi := l.NextToken()
+	assert.Equal(t, token.TokenType(token.SEMICOLON), semi.Type)
+	assert.Equal(t, 7, semi.Pos.Column)
+	l.NextToken()
+	semi = l.NextToken()
+	assert.Equal(t, token.TokenType(token.SEMICOLON), semi.Type)
+	assert.Equal(t, 2, semi.Pos.Line)
+	assert.Equal(t, 3, semi.Pos.Column)
+}
+
+func TestIndentation(t *testing.T) {
+	input := `let f = fn(x):
+    if (x):
+        return [
+  1]
+    else:
+        // comment
+        let y = x +
+                1
+        y
+
+f(true)`
+
+	expected := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.FUNCTION, token.LPAREN, token.IDENT, token.RPAREN, token.COLON,
+		token.INDENT, token.IF, token.LPAREN, token.IDENT, token.RPAREN, token.COLON,
+		token.INDENT, token.RETURN, token.LBRACKET, token.INT, token.RBRACKET,
+		token.DEDENT, token.ELSE, token.COLON,
+		token.INDENT,
+		token.LET, token.IDENT, token.ASSIGN, token.IDENT, token.PLUS, token.INT, token.SEMICOLON,
+		token.IDENT,
+		token.DEDENT, token.DEDENT, token.SEMICOLON,
+		token.IDENT, token.LPAREN, token.TRUE, token.RPAREN,
+		token.EOF,
+	}
+
+	l := New(input)
+	l.EnableIndentation()
+
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+	types = append(types, token.EOF)
+
+	assert.Equal(t, expected, types)
+	assert.Empty(t, l.Errors())
+}
+
+func TestIndentationEOF(t *testing.T) {
+	l := New("fn():\n\tif (x):\n\t\ty")
+	l.EnableIndentation()
+
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+
+	assert.Equal(t, []token.TokenType{
+		token.FUNCTION, token.LPAREN, token.RPAREN, token.COLON, token.INDENT,
+		token.IF, token.LPAREN, token.IDENT, token.RPAREN, token.COLON, token.INDENT,
+		token.IDENT, token.DEDENT, token.DEDENT,
+	}, types)
+}
+
+func TestIndentationErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"a:\n \tb", "2:1: indentation mixes tabs and spaces"},
+		{"a:\n\tb\nc:\n  d", "4:1: indentation mixes tabs and spaces"},
+		{"a:\n    b\n  c", "3:3: unindent does not match any outer indentation level"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.EnableIndentation()
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		if assert.Len(t, l.Errors(), 1, tt.input) {
+			assert.Equal(t, tt.expectedError, l.Errors()[0].Error(), tt.input)
+		}
+	}
+
+	// without indentation mode there are no layout tokens
+	tokens, err := Tokenize("a:\n  b")
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 4)
+}
+
+func TestComments(t *testing.T) {
+	input := `#!/usr/bin/env monkey
+// leading
+/* block /* nested */ still */ let x = 1; // trailing
+let y /* inline */ = x / 2 // end
+// at the end`
+
+	type comments []token.Comment
+	c := func(line, column, offset int, text string) token.Comment {
+		return token.Comment{Pos: token.Position{Offset: offset, Line: line, Column: column}, Text: text}
+	}
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLeading  comments
+		expectedTrailing comments
+	}{
+		{token.LET, comments{
+			c(1, 1, 0, "#!/usr/bin/env monkey"),
+			c(2, 1, 22, "// leading"),
+			c(3, 1, 33, "/* block /* nested */ still */"),
+		}, nil},
+		{token.IDENT, nil, nil},
+		{token.ASSIGN, nil, nil},
+		{token.INT, nil, nil},
+		{token.SEMICOLON, nil, comments{c(3, 43, 75, "// trailing")}},
+		{token.LET, nil, nil},
+		{token.IDENT, nil, comments{c(4, 7, 93, "/* inline */")}},
+		{token.ASSIGN, nil, nil},
+		{token.IDENT, nil, nil},
+		{token.SLASH, nil, nil},
+		{token.INT, nil, comments{c(4, 28, 114, "// end")}},
+		{token.EOF, comments{c(5, 1, 121, "// at the end")}, nil},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+
+		assert.Equal(t, tt.expectedType, tok.Type, tok.Literal)
+		assert.Equal(t, []token.Comment(tt.expectedLeading), tok.Leading, tok.Literal)
+		assert.Equal(t, []token.Comment(tt.expectedTrailing), tok.Trailing, tok.Literal)
+	}
+
+	assert.Empty(t, l.Errors())
+}
+
+func TestCommentErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+		expectedText  string
+	}{
+		{"x /* open", "1:3: comment not terminated", "/* open"},
+		{"x /* a /* b */", "1:3: comment not terminated", "/* a /* b */"},
+		{"x /*/", "1:3: comment not terminated", "/*/"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if assert.Len(t, tok.Trailing, 1, tt.input) {
+			assert.Equal(t, tt.expectedText, tok.Trailing[0].Text, tt.input)
+		}
+		assert.Equal(t, token.TokenType(token.EOF), l.NextToken().Type, tt.input)
+
+		if assert.Len(t, l.Errors(), 1, tt.input) {
+			assert.Equal(t, tt.expectedError, l.Errors()[0].Error(), tt.input)
+		}
+	}
+
+	// a shebang is only a comment on the first line
+	l := New("x;\n#!y")
+	l.NextToken()
+	l.NextToken()
+	assert.Equal(t, token.TokenType(token.ILLEGAL), l.NextToken().Type)
+}
+
+// lexerInputs is shared by the tests comparing the string lexer with the
+// reader lexer.
+var lexerInputs = []string{
+	"",
+	"let five = 5;\nlet add = fn(x, y) { x + y; };\nadd(five, 10)\n",
+	"if (5 < 10) { return true; } else { return false; }",
+	`10 == 10; 10 != 9; [1, 2]; {"foo": "bar"}; a <= b >= c && d || e % f ** g`,
+	"x += 1 -= 2 *= 3 /= 4 = 5",
+	"0x1F 0o17 0b1010 1_000 3.14 1e-9 6.02E+23 1..10 0x 1e 012",
+	`"esc\n\t\"\\" "\u{1F600}" "bad\q" "open`,
+	"let café = \"naïve ✓\";\nπ2 + 日本語 ≠",
+	"é\xff x = \xc3; \"a\xe2\x9c\"",
+	"#!/usr/bin/env monkey\n// c\n/* a /* b */ c */ x // t\r\ny /* open",
+	"empty? push! a!=b c?==d",
+	"let s = \"" + strings.Repeat("long string ", 1000) + "\";\n",
+	strings.Repeat("let x = 1; // comment\n", 500),
+}
+
+// indentedInputs are compared like lexerInputs, in indentation mode.
+var indentedInputs = []string{
+	"if (x):\n    y\nelse:\n    z\n",
+	"fn():\n\tif (x):\n\t\treturn\n\ty\nz",
+	"return \n /* c */ / ",
+	"if (x):\n    /* " + strings.Repeat("long comment ", 1000) + "*/ y\n    z\n",
+	"if (x):\n" + strings.Repeat("    let x = [1,\n      2] // comment\n", 500),
+	"if (x):\n  \t y\n z",
+}
+
+func lexAll(l *Lexer) []token.Token {
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	readers := map[string]func(io.Reader) io.Reader{
+		"whole":    func(r io.Reader) io.Reader { return r },
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data eof": iotest.DataErrReader,
+	}
+
+	inputs := map[string]bool{}
+	for _, input := range lexerInputs {
+		inputs[input] = false
+	}
+	for _, input := range indentedInputs {
+		inputs[input] = true
+	}
+
+	for input, indented := range inputs {
+		l := New(input)
+		if indented {
+			l.EnableIndentation()
+		}
+		expected := lexAll(l)
+
+		for name, reader := range readers {
+			rl := NewReader(reader(strings.NewReader(input)))
+			if indented {
+				rl.EnableIndentation()
+			}
+			msg := fmt.Sprintf("%s: %.40q", name, input)
+
+			assert.Equal(t, expected, lexAll(rl), msg)
+			assert.Equal(t, l.Errors(), rl.Errors(), msg)
+			assert.Equal(t, l.File().Size(), rl.File().Size(), msg)
+			assert.Equal(t, l.File().LineCount(), rl.File().LineCount(), msg)
+		}
+	}
+
+	fset := token.NewFileSet()
+	rl := NewReaderFile(fset.AddFile("in.mk", 0), strings.NewReader("a\n  b"))
+	lexAll(rl)
+	assert.Equal(t, token.Position{Filename: "in.mk", Offset: 4, Line: 2, Column: 3}, fset.Position(rl.File().Pos(4)))
+}
+
+func TestNewReaderBuffer(t *testing.T) {
+	line := "let x = [1, 2, 3]; // comment\n"
+	l := NewReader(strings.NewReader(strings.Repeat(line, 20000)))
+
+	tokens := lexAll(l)
+	assert.Len(t, tokens, 20000*11+1)
+	assert.Empty(t, l.Errors())
+	// the buffer does not grow with the input
+	assert.True(t, cap(l.buf) <= 4*chunkSize, "buffer capacity %d", cap(l.buf))
+
+	last := tokens[len(tokens)-2]
+	assert.Equal(t, token.Position{Offset: 19999*len(line) + 17, Line: 20000, Column: 18}, last.Pos)
+}
+
+func TestNewReaderError(t *testing.T) {
+	l := NewReader(iotest.TimeoutReader(strings.NewReader("let x = 1;")))
+
+	tokens := lexAll(l)
+	assert.Len(t, tokens, 6)
+	if assert.Len(t, l.Errors(), 1) {
+		assert.Equal(t, "1:11: read error: timeout", l.Errors()[0].Error())
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("let x = 1; // one")
+	assert.NoError(t, err)
+
+	var types []token.TokenType
+	for _, tok := range tokens {
+		types = append(types, tok.Type)
+	}
+	assert.Equal(t, []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.EOF}, types)
+	assert.Equal(t, "// one", tokens[4].Trailing[0].Text)
+
+	for _, input := range lexerInputs {
+		tokens, _ := Tokenize(input)
+		assert.Equal(t, lexAll(New(input)), tokens)
+	}
+
+	tokens, err = Tokenize(`0x "open`)
+	assert.Len(t, tokens, 3)
+	if assert.Error(t, err) {
+		assert.Len(t, err.(ErrorList), 2)
+		assert.Equal(t, "1:1: hexadecimal literal has no digits (and 1 more errors)", err.Error())
+	}
+}
+
+func TestScanner(t *testing.T) {
+	s := NewScanner(NewReader(strings.NewReader("a + b")))
+
+	var literals []string
+	for s.Scan() {
+		literals = append(literals, s.Token().Literal)
+	}
+
+	assert.Equal(t, []string{"a", "+", "b", ""}, literals)
+	assert.Equal(t, token.TokenType(token.EOF), s.Token().Type)
+	assert.False(t, s.Scan())
+	assert.NoError(t, s.Err())
+}
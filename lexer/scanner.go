@@ -0,0 +1,86 @@
+package lexer
+
+import (
+	"fmt"
+
+	"github.com/Gonzih/go-interpreter/token"
+)
+
+// ErrorList is a list of lexical errors, in source order.
+type ErrorList []*Error
+
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", list[0], len(list)-1)
+}
+
+// Err returns the list as an error, or nil when it is empty.
+func (list ErrorList) Err() error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	return list
+}
+
+// Scanner iterates over the tokens of a lexer up to and including EOF:
+//
+//	s := lexer.NewScanner(lexer.New(src))
+//	for s.Scan() {
+//		tok := s.Token()
+//		...
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+type Scanner struct {
+	l    *Lexer
+	tok  token.Token
+	done bool
+}
+
+func NewScanner(l *Lexer) *Scanner {
+	return &Scanner{l: l}
+}
+
+// Scan advances to the next token, it returns false once the EOF token was
+// returned.
+func (s *Scanner) Scan() bool {
+	if s.done {
+		return false
+	}
+
+	s.tok = s.l.NextToken()
+	s.done = s.tok.Type == token.EOF
+
+	return true
+}
+
+// Token returns the token of the last call to Scan.
+func (s *Scanner) Token() token.Token {
+	return s.tok
+}
+
+// Err returns the lexical errors found so far as an ErrorList, or nil.
+func (s *Scanner) Err() error {
+	return ErrorList(s.l.Errors()).Err()
+}
+
+// Tokenize returns all tokens of src, the last one is EOF. Lexical errors
+// do not stop it, they are returned together as an ErrorList.
+func Tokenize(src string) ([]token.Token, error) {
+	var tokens []token.Token
+
+	s := NewScanner(New(src))
+	for s.Scan() {
+		tokens = append(tokens, s.Token())
+	}
+
+	return tokens, s.Err()
+}
@@ -1,19 +1,150 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 
+	"github.com/Gonzih/go-interpreter/lexer"
 	"github.com/Gonzih/go-interpreter/repl"
+	"github.com/Gonzih/go-interpreter/token"
 )
 
+const usage = `usage:
+  go-interpreter                       start the REPL
+  go-interpreter repl                  start the REPL
+  go-interpreter tokens [--json] file  print the tokens of file, - for stdin
+`
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command in args and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return startRepl(stdin, stdout)
+	}
+
+	switch args[0] {
+	case "repl":
+		return startRepl(stdin, stdout)
+	case "tokens":
+		return tokens(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+}
+
+func startRepl(stdin io.Reader, stdout io.Writer) int {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
-	fmt.Printf("Hello %s, welcome to the REPL\n", user.Username)
-	repl.Start(os.Stdin, os.Stdout)
+	fmt.Fprintf(stdout, "Hello %s, welcome to the REPL\n", user.Username)
+	repl.Start(stdin, stdout)
+
+	return 0
+}
+
+type jsonComment struct {
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type jsonToken struct {
+	Type     token.TokenType `json:"type"`
+	Literal  string          `json:"literal"`
+	Offset   int             `json:"offset"`
+	Line     int             `json:"line"`
+	Column   int             `json:"column"`
+	Leading  []jsonComment   `json:"leading,omitempty"`
+	Trailing []jsonComment   `json:"trailing,omitempty"`
+}
+
+func toJSONComments(comments []token.Comment) []jsonComment {
+	var out []jsonComment
+	for _, c := range comments {
+		out = append(out, jsonComment{Text: c.Text, Line: c.Pos.Line, Column: c.Pos.Column})
+	}
+
+	return out
+}
+
+// tokens prints one token per line as position, type and quoted literal,
+// or all of them as a JSON array. Lexical errors go to stderr.
+func tokens(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "print the tokens as a JSON array")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	filename := fs.Arg(0)
+	in := stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// the input is lexed as it is read, it is never held in memory as a whole
+	fset := token.NewFileSet()
+	s := lexer.NewScanner(lexer.NewReaderFile(fset.AddFile(filename, 0), in))
+
+	var list []jsonToken
+	for s.Scan() {
+		tok := s.Token()
+		if !*asJSON {
+			fmt.Fprintf(stdout, "%s\t%s\t%q\n", tok.Pos, tok.Type, tok.Literal)
+			continue
+		}
+
+		list = append(list, jsonToken{
+			Type:     tok.Type,
+			Literal:  tok.Literal,
+			Offset:   tok.Pos.Offset,
+			Line:     tok.Pos.Line,
+			Column:   tok.Pos.Column,
+			Leading:  toJSONComments(tok.Leading),
+			Trailing: toJSONComments(tok.Trailing),
+		})
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		for _, e := range err.(lexer.ErrorList) {
+			fmt.Fprintln(stderr, e)
+		}
+		return 1
+	}
+
+	return 0
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokensCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	status := run([]string{"tokens", "-"}, strings.NewReader("let s = \"a\\n\";"), &stdout, &stderr)
+	assert.Equal(t, 0, status)
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, `-:1:1	LET	"let"
+-:1:5	IDENT	"s"
+-:1:7	=	"="
+-:1:9	STRING	"a\n"
+-:1:14	;	";"
+-:1:15	EOF	""
+`, stdout.String())
+}
+
+func TestTokensCommandJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	status := run([]string{"tokens", "--json", "-"}, strings.NewReader("x // c\n"), &stdout, &stderr)
+	assert.Equal(t, 0, status)
+
+	var tokens []jsonToken
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &tokens))
+	assert.Equal(t, []jsonToken{
+		{Type: "IDENT", Literal: "x", Offset: 0, Line: 1, Column: 1,
+			Trailing: []jsonComment{{Text: "// c", Line: 1, Column: 3}}},
+		{Type: "EOF", Literal: "", Offset: 7, Line: 2, Column: 1},
+	}, tokens)
+}
+
+func TestTokensCommandErrors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		status int
+		stderr string
+	}{
+		{[]string{"tokens", "-"}, "1e", 1, "-:1:1: exponent has no digits\n"},
+		{[]string{"tokens"}, "", 2, usage},
+		{[]string{"tokens", "does-not-exist.mk"}, "", 1, "open does-not-exist.mk: no such file or directory\n"},
+		{[]string{"nope"}, "", 2, "unknown command \"nope\"\n" + usage},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		status := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		assert.Equal(t, tt.status, status, tt.args)
+		assert.Equal(t, tt.stderr, stderr.String(), tt.args)
+	}
+}
+
+func TestTokensCommandReadError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	status := run([]string{"tokens", "-"}, iotest.ErrReader(errors.New("broken pipe")), &stdout, &stderr)
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "-:1:1\tEOF\t\"\"\n", stdout.String())
+	assert.Equal(t, "-:1:1: read error: broken pipe\n", stderr.String())
+}
@@ -0,0 +1,71 @@
+package object
+
+import "fmt"
+
+// BindArguments assigns the arguments of a call to the parameters of a
+// function. params are the parameter names, the first required of them
+// have no default, and with rest the positional arguments left over are
+// collected in an array. Named arguments come as names with their values.
+// It returns the value of every parameter, nil for one left to its
+// default, followed by the rest array if there is one.
+func BindArguments(params []string, required int, rest bool, args []Object, names []string, named []Object) ([]Object, error) {
+	n := len(params)
+	if len(args) > n && !rest {
+		return nil, fmt.Errorf("wrong number of arguments: want=%d, got=%d", n, len(args))
+	}
+
+	bound := make([]Object, n, n+1)
+	copy(bound, args)
+	if rest {
+		extra := []Object{}
+		if len(args) > n {
+			extra = append(extra, args[n:]...)
+		}
+		bound = append(bound, &Array{Elements: extra})
+	}
+
+	for i, name := range names {
+		j := indexOf(params, name)
+		if j < 0 {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+		if bound[j] != nil {
+			return nil, fmt.Errorf("argument %s is passed more than once", name)
+		}
+		bound[j] = named[i]
+	}
+
+	for i := 0; i < required; i++ {
+		if bound[i] != nil {
+			continue
+		}
+		// a plain function only takes its exact number of arguments
+		if required == n && !rest && len(names) == 0 {
+			return nil, fmt.Errorf("wrong number of arguments: want=%d, got=%d", n, len(args))
+		}
+		return nil, fmt.Errorf("missing argument %s", params[i])
+	}
+
+	return bound, nil
+}
+
+// Spread returns the elements a "..." argument passes, only an array can be
+// spread.
+func Spread(value Object) ([]Object, error) {
+	array, ok := value.(*Array)
+	if !ok {
+		return nil, fmt.Errorf("cannot spread %s", value.Type())
+	}
+
+	return array.Elements, nil
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+
+	return -1
+}
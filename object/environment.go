@@ -0,0 +1,51 @@
+package object
+
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+// NewEnclosedEnvironment creates a child scope that falls back to outer
+// for names it does not define itself, used for function calls.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+
+	return env
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+
+	return obj, ok
+}
+
+// Scope returns the environment name is defined in, looking through the
+// outer environments, or nil when it is not defined at all.
+func (e *Environment) Scope(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env
+		}
+	}
+
+	return nil
+}
+
+// IsGlobal reports whether e is the outermost environment.
+func (e *Environment) IsGlobal() bool {
+	return e.outer == nil
+}
+
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+
+	return val
+}
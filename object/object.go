@@ -0,0 +1,363 @@
+package object
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/Gonzih/go-interpreter/ast"
+	"github.com/Gonzih/go-interpreter/code"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	NULL_OBJ         = "NULL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	STRING_OBJ       = "STRING"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ITERATOR_OBJ     = "ITERATOR"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
+	PATTERN_OBJ           = "PATTERN"
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect always shows a float as one, 1.0 is not printed as 1.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+
+	return s
+}
+
+// Number returns the value of an integer or a float object as a float64,
+// ok is false for any other object.
+func Number(obj Object) (value float64, ok bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	}
+
+	return 0, false
+}
+
+// IsNumber reports whether obj is an integer or a float.
+func IsNumber(obj Object) bool {
+	_, ok := Number(obj)
+	return ok
+}
+
+// Pow raises base to a non-negative exponent by squaring.
+func Pow(base, exp int64) int64 {
+	result := int64(1)
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+	}
+
+	return result
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out strings.Builder
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by objects that can be used as hash keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	// Keys holds the keys of Pairs in the order they were first set
+	Keys []HashKey
+}
+
+// NewHash creates an empty hash.
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds a pair, or replaces the value of an existing key in place.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out strings.Builder
+
+	pairs := []string{}
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// SetIndex stores value in an array element or under a key of a hash, the
+// element of an array must already exist.
+func SetIndex(left, index, value Object) error {
+	switch left := left.(type) {
+	case *Array:
+		i, ok := index.(*Integer)
+		if !ok {
+			return fmt.Errorf("array index must be INTEGER, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return fmt.Errorf("index out of range: %d", i.Value)
+		}
+		left.Elements[i.Value] = value
+	case *Hash:
+		key, ok := index.(Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+		left.Set(key.HashKey(), HashPair{Key: index, Value: value})
+	default:
+		return fmt.Errorf("index assignment not supported: %s", left.Type())
+	}
+
+	return nil
+}
+
+// Break and Continue are passed up from a break or continue statement to
+// the loop it is in, like return values are passed up to the function.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// Iterator steps through the elements of an array, the characters of a
+// string or the keys of a hash, for a for-in loop.
+type Iterator struct {
+	items []Object
+	next  int
+}
+
+// NewIterator creates an iterator over obj, ok is false when obj can not
+// be iterated over. Keys added to a hash after it is created are skipped.
+func NewIterator(obj Object) (iterator *Iterator, ok bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return &Iterator{items: obj.Elements}, true
+	case *String:
+		items := []Object{}
+		for _, ch := range obj.Value {
+			items = append(items, &String{Value: string(ch)})
+		}
+		return &Iterator{items: items}, true
+	case *Hash:
+		items := make([]Object, len(obj.Keys))
+		for i, key := range obj.Keys {
+			items[i] = obj.Pairs[key].Key
+		}
+		return &Iterator{items: items}, true
+	}
+
+	return nil, false
+}
+
+// Next returns the next item, ok is false once all of them were returned.
+func (it *Iterator) Next() (item Object, ok bool) {
+	if it.next >= len(it.items) {
+		return nil, false
+	}
+
+	it.next++
+
+	return it.items[it.next-1], true
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+type Function struct {
+	Parameters []*ast.Identifier
+	// Defaults and Rest are those of the ast.FunctionLiteral
+	Defaults []ast.Expression
+	Rest     *ast.Identifier
+	Body     *ast.BlockStatement
+	Env      *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out strings.Builder
+
+	params := []string{}
+	for i, p := range f.Parameters {
+		if f.Defaults != nil && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	// Parameters names the parameters for named arguments, the first
+	// Required of them have no default. With Rest the arguments after them
+	// are collected in an array in the next local.
+	Parameters []string
+	Required   int
+	Rest       bool
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure is the runtime representation of a compiled function together
+// with the free variables it captured when it was created.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
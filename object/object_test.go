@@ -0,0 +1,272 @@
+package object
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Gonzih/go-interpreter/ast"
+	"github.com/Gonzih/go-interpreter/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff := &String{Value: "My name is johnny"}
+
+	assert.Equal(t, hello1.HashKey(), hello2.HashKey())
+	assert.NotEqual(t, hello1.HashKey(), diff.HashKey())
+}
+
+func TestHashKeyTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	assert.Equal(t, one.HashKey(), (&Integer{Value: 1}).HashKey())
+	assert.Equal(t, yes.HashKey(), (&Boolean{Value: true}).HashKey())
+	// same underlying value, different types
+	assert.NotEqual(t, one.HashKey(), yes.HashKey())
+}
+
+func TestHashInspect(t *testing.T) {
+	hash := NewHash()
+	for i, name := range []string{"d", "a", "c", "b", "a"} {
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: &Integer{Value: int64(i)}})
+	}
+
+	assert.Equal(t, "{d: 0, a: 4, c: 2, b: 3}", hash.Inspect())
+	assert.Equal(t, "{}", NewHash().Inspect())
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{-2.5, "-2.5"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, (&Float{Value: tt.value}).Inspect())
+	}
+}
+
+func TestPow(t *testing.T) {
+	tests := []struct {
+		base, exp, expected int64
+	}{
+		{2, 10, 1024},
+		{-2, 3, -8},
+		{7, 0, 1},
+		{0, 0, 1},
+		{3, 1, 3},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Pow(tt.base, tt.exp), "%d ** %d", tt.base, tt.exp)
+	}
+}
+
+func TestIterator(t *testing.T) {
+	hash := NewHash()
+	for _, name := range []string{"b", "a"} {
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: &Integer{Value: 1}})
+	}
+
+	tests := []struct {
+		iterable Object
+		expected []string
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}, []string{"1", "x"}},
+		{&Array{}, []string{}},
+		{&String{Value: "héj"}, []string{"h", "é", "j"}},
+		{hash, []string{"b", "a"}},
+	}
+
+	for _, tt := range tests {
+		it, ok := NewIterator(tt.iterable)
+		assert.True(t, ok, tt.iterable.Inspect())
+
+		items := []string{}
+		for item, ok := it.Next(); ok; item, ok = it.Next() {
+			items = append(items, item.Inspect())
+		}
+		assert.Equal(t, tt.expected, items, tt.iterable.Inspect())
+	}
+
+	_, ok := NewIterator(&Integer{Value: 1})
+	assert.False(t, ok)
+}
+
+func TestSetIndex(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}
+	assert.NoError(t, SetIndex(array, &Integer{Value: 1}, &String{Value: "x"}))
+	assert.Equal(t, "[1, x]", array.Inspect())
+
+	hash := NewHash()
+	for _, key := range []string{"a", "b", "a"} {
+		assert.NoError(t, SetIndex(hash, &String{Value: key}, &String{Value: key + "!"}))
+	}
+	assert.Equal(t, "{a: a!, b: b!}", hash.Inspect())
+
+	tests := []struct {
+		left, index Object
+		expected    string
+	}{
+		{array, &Integer{Value: 2}, "index out of range: 2"},
+		{array, &Integer{Value: -1}, "index out of range: -1"},
+		{array, &String{Value: "a"}, "array index must be INTEGER, got STRING"},
+		{hash, array, "unusable as hash key: ARRAY"},
+		{&String{Value: "abc"}, &Integer{Value: 0}, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		assert.EqualError(t, SetIndex(tt.left, tt.index, &Integer{Value: 0}), tt.expected)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1}, true},
+		{&Float{Value: 0.5}, &Integer{Value: 0}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "1"}, &Integer{Value: 1}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Null{}, &Null{}, true},
+		{&Array{}, &Array{}, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Equal(tt.a, tt.b), "%s == %s", tt.a.Inspect(), tt.b.Inspect())
+	}
+}
+
+func TestMatch(t *testing.T) {
+	hash := NewHash()
+	for i, name := range []string{"a", "b", "c"} {
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: &Integer{Value: int64(i)}})
+	}
+	array := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}, hash}}
+
+	tests := []struct {
+		pattern  string
+		value    Object
+		names    []string
+		expected []string
+	}{
+		{"_", array, []string{}, []string{}},
+		{"v", array, []string{"v"}, []string{array.Inspect()}},
+		{"-1", &Integer{Value: -1}, []string{}, []string{}},
+		{"-1", &Integer{Value: 1}, nil, nil},
+		{`[1, "x", h]`, array, []string{"h"}, []string{hash.Inspect()}},
+		{"[a, ...rest]", array, []string{"a", "rest"}, []string{"1", `[x, {a: 0, b: 1, c: 2}]`}},
+		{"[a, ...]", array, []string{"a"}, []string{"1"}},
+		{"[a, b]", array, nil, nil},
+		{"[a, b, c, d, ...]", array, nil, nil},
+		{`[_, _, {"b": b, ...others}]`, array, []string{"b", "others"}, []string{"1", "{a: 0, c: 2}"}},
+		{"{a, c}", hash, []string{"a", "c"}, []string{"0", "2"}},
+		{"{d}", hash, nil, nil},
+		{`{"a": 1}`, hash, nil, nil},
+		{"{a}", array, nil, nil},
+		{"n if n > 1", &Integer{Value: 0}, []string{"n"}, []string{"0"}},
+	}
+
+	for _, tt := range tests {
+		program, err := parser.Parse("match x { " + tt.pattern + " => 0 }")
+		assert.NoError(t, err, tt.pattern)
+		if err != nil {
+			continue
+		}
+		pattern := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression).Arms[0].Pattern
+
+		bound, ok := Match(pattern, tt.value)
+		if tt.expected == nil {
+			assert.False(t, ok, tt.pattern)
+			continue
+		}
+
+		assert.True(t, ok, tt.pattern)
+		assert.Equal(t, tt.names, Bindings(pattern), tt.pattern)
+
+		values := []string{}
+		for _, v := range bound {
+			values = append(values, v.Inspect())
+		}
+		assert.Equal(t, tt.expected, values, tt.pattern)
+	}
+}
+
+func TestBindArguments(t *testing.T) {
+	one, two, three := &Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}
+
+	tests := []struct {
+		params   []string
+		required int
+		rest     bool
+		args     []Object
+		names    []string
+		named    []Object
+		expected string
+	}{
+		{[]string{"a", "b"}, 2, false, []Object{one, two}, nil, nil, "1 2"},
+		{[]string{"a", "b"}, 1, false, []Object{one}, nil, nil, "1 -"},
+		{[]string{"a", "b"}, 1, false, nil, []string{"b", "a"}, []Object{two, one}, "1 2"},
+		{[]string{"a"}, 1, true, []Object{one, two, three}, nil, nil, "1 [2, 3]"},
+		{[]string{"a"}, 0, true, nil, nil, nil, "- []"},
+		{[]string{"a", "b", "c"}, 1, false, []Object{one}, []string{"c"}, []Object{three}, "1 - 3"},
+	}
+
+	for _, tt := range tests {
+		bound, err := BindArguments(tt.params, tt.required, tt.rest, tt.args, tt.names, tt.named)
+		assert.NoError(t, err)
+
+		values := []string{}
+		for _, value := range bound {
+			if value == nil {
+				values = append(values, "-")
+			} else {
+				values = append(values, value.Inspect())
+			}
+		}
+		assert.Equal(t, tt.expected, strings.Join(values, " "))
+	}
+
+	errors := []struct {
+		params   []string
+		required int
+		args     []Object
+		names    []string
+		expected string
+	}{
+		{[]string{"a"}, 1, nil, nil, "wrong number of arguments: want=1, got=0"},
+		{[]string{"a"}, 1, []Object{one, two}, nil, "wrong number of arguments: want=1, got=2"},
+		{[]string{"a", "b"}, 1, nil, nil, "missing argument a"},
+		{[]string{"a", "b"}, 2, nil, []string{"b"}, "missing argument a"},
+		{[]string{"a"}, 1, nil, []string{"x"}, "unknown parameter x"},
+		{[]string{"a"}, 1, []Object{one}, []string{"a"}, "argument a is passed more than once"},
+	}
+
+	for _, tt := range errors {
+		named := make([]Object, len(tt.names))
+		for i := range named {
+			named[i] = one
+		}
+		_, err := BindArguments(tt.params, tt.required, false, tt.args, tt.names, named)
+		assert.EqualError(t, err, tt.expected)
+	}
+
+	_, err := Spread(one)
+	assert.EqualError(t, err, "cannot spread INTEGER")
+}
@@ -0,0 +1,209 @@
+package object
+
+import (
+	"fmt"
+
+	"github.com/Gonzih/go-interpreter/ast"
+)
+
+// Pattern is an ast.Pattern as a constant of compiled code, the VM matches
+// values against it.
+type Pattern struct {
+	Pattern ast.Pattern
+}
+
+func (p *Pattern) Type() ObjectType { return PATTERN_OBJ }
+func (p *Pattern) Inspect() string  { return p.Pattern.String() }
+
+// Equal reports whether a and b are equal the way == compares them: numbers
+// by value, also an integer and a float, strings, booleans and null by
+// value, other objects only when they are the same object.
+func Equal(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value == b.Value
+		}
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	}
+
+	if IsNumber(a) && IsNumber(b) {
+		x, _ := Number(a)
+		y, _ := Number(b)
+		return x == y
+	}
+
+	return a == b
+}
+
+// Bindings returns the names bound by pattern, in the order Match returns
+// their values.
+func Bindings(pattern ast.Pattern) []string {
+	names := []string{}
+
+	var walk func(pattern ast.Pattern)
+	walk = func(pattern ast.Pattern) {
+		switch pattern := pattern.(type) {
+		case *ast.BindingPattern:
+			names = append(names, pattern.Name.Value)
+		case *ast.RestPattern:
+			if pattern.Name != nil {
+				names = append(names, pattern.Name.Value)
+			}
+		case *ast.GuardPattern:
+			walk(pattern.Pattern)
+		case *ast.ArrayPattern:
+			for _, el := range pattern.Elements {
+				walk(el)
+			}
+			if pattern.Rest != nil {
+				walk(pattern.Rest)
+			}
+		case *ast.HashPattern:
+			for _, pair := range pattern.Pairs {
+				walk(pair.Value)
+			}
+			if pattern.Rest != nil {
+				walk(pattern.Rest)
+			}
+		}
+	}
+	walk(pattern)
+
+	return names
+}
+
+// Match matches value against pattern, ok is false when it does not match.
+// The values bound by the pattern are returned in the order of Bindings. A
+// rest pattern binds a new array or hash with what is left. The guard of a
+// GuardPattern is not evaluated, that is up to the caller.
+func Match(pattern ast.Pattern, value Object) (bound []Object, ok bool) {
+	bound = []Object{}
+	if !match(pattern, value, &bound) {
+		return nil, false
+	}
+
+	return bound, true
+}
+
+// Destructure is Match for a let, which has no other pattern to fall
+// back to, so a value that does not match is an error.
+func Destructure(pattern ast.Pattern, value Object) ([]Object, error) {
+	bound, ok := Match(pattern, value)
+	if !ok {
+		return nil, fmt.Errorf("cannot destructure %s with %s", value.Type(), pattern.String())
+	}
+
+	return bound, nil
+}
+
+func match(pattern ast.Pattern, value Object, bound *[]Object) bool {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true
+	case *ast.BindingPattern:
+		*bound = append(*bound, value)
+		return true
+	case *ast.LiteralPattern:
+		return Equal(literal(pattern.Value), value)
+	case *ast.GuardPattern:
+		return match(pattern.Pattern, value, bound)
+	case *ast.ArrayPattern:
+		return matchArray(pattern, value, bound)
+	case *ast.HashPattern:
+		return matchHash(pattern, value, bound)
+	}
+
+	return false
+}
+
+func matchArray(pattern *ast.ArrayPattern, value Object, bound *[]Object) bool {
+	array, ok := value.(*Array)
+	if !ok {
+		return false
+	}
+
+	n := len(pattern.Elements)
+	if len(array.Elements) < n || len(array.Elements) > n && pattern.Rest == nil {
+		return false
+	}
+
+	for i, el := range pattern.Elements {
+		if !match(el, array.Elements[i], bound) {
+			return false
+		}
+	}
+
+	if pattern.Rest != nil && pattern.Rest.Name != nil {
+		rest := make([]Object, len(array.Elements)-n)
+		copy(rest, array.Elements[n:])
+		*bound = append(*bound, &Array{Elements: rest})
+	}
+
+	return true
+}
+
+func matchHash(pattern *ast.HashPattern, value Object, bound *[]Object) bool {
+	hash, ok := value.(*Hash)
+	if !ok {
+		return false
+	}
+
+	matched := map[HashKey]bool{}
+	for _, p := range pattern.Pairs {
+		key, ok := literal(p.Key).(Hashable)
+		if !ok {
+			return false
+		}
+
+		pair, ok := hash.Pairs[key.HashKey()]
+		if !ok || !match(p.Value, pair.Value, bound) {
+			return false
+		}
+		matched[key.HashKey()] = true
+	}
+
+	if pattern.Rest != nil && pattern.Rest.Name != nil {
+		rest := NewHash()
+		for _, key := range hash.Keys {
+			if !matched[key] {
+				rest.Set(key, hash.Pairs[key])
+			}
+		}
+		*bound = append(*bound, rest)
+	}
+
+	return true
+}
+
+// literal returns the value of a literal in a pattern, a number can be
+// negated.
+func literal(exp ast.Expression) Object {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &Integer{Value: exp.Value}
+	case *ast.FloatLiteral:
+		return &Float{Value: exp.Value}
+	case *ast.StringLiteral:
+		return &String{Value: exp.Value}
+	case *ast.Boolean:
+		return &Boolean{Value: exp.Value}
+	case *ast.PrefixExpression:
+		switch value := literal(exp.Right).(type) {
+		case *Integer:
+			return &Integer{Value: -value.Value}
+		case *Float:
+			return &Float{Value: -value.Value}
+		}
+	}
+
+	return nil
+}
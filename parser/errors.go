@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Gonzih/go-interpreter/token"
+)
+
+// ErrorCode identifies the kind of a ParseError independently of its
+// human readable message.
+type ErrorCode string
+
+const (
+	ErrUnexpectedToken  ErrorCode = "unexpected-token"
+	ErrNoPrefixParseFn  ErrorCode = "no-prefix-parse-fn"
+	ErrInvalidInteger   ErrorCode = "invalid-integer"
+	ErrInvalidFloat     ErrorCode = "invalid-float"
+	ErrLexical          ErrorCode = "lexical"
+	ErrOutsideLoop      ErrorCode = "outside-loop"
+	ErrInvalidTarget    ErrorCode = "invalid-assignment-target"
+	ErrReservedName     ErrorCode = "reserved-name"
+	ErrDuplicateDefault ErrorCode = "duplicate-default"
+	ErrInvalidPattern   ErrorCode = "invalid-pattern"
+	ErrUnreachableArm   ErrorCode = "unreachable-arm"
+	ErrDuplicateName    ErrorCode = "duplicate-name"
+	ErrInvalidParameter ErrorCode = "invalid-parameter"
+	ErrInvalidArgument  ErrorCode = "invalid-argument"
+)
+
+type ParseError struct {
+	Pos  token.Position
+	Code ErrorCode
+	// Found is the offending token
+	Found token.Token
+	// Expected lists token types that would have been accepted instead,
+	// it is empty when the parser can not tell
+	Expected []token.TokenType
+	Msg      string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Render formats the error followed by the offending line of src and a
+// caret underline below the found token.
+func (e *ParseError) Render(src string) string {
+	var out strings.Builder
+
+	out.WriteString(e.Error())
+	out.WriteString("\n")
+
+	if !e.Pos.IsValid() || e.Pos.Offset > len(src) {
+		return out.String()
+	}
+
+	start := strings.LastIndexByte(src[:e.Pos.Offset], '\n') + 1
+	end := strings.IndexByte(src[e.Pos.Offset:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += e.Pos.Offset
+	}
+
+	line := src[start:end]
+	out.WriteString(line)
+	out.WriteString("\n")
+
+	// keep tabs so that the caret lines up with the source line
+	for _, ch := range src[start:e.Pos.Offset] {
+		if ch == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+
+	width := utf8.RuneCountInString(e.Found.Literal)
+	if width < 1 || e.Pos.Offset+width > end {
+		width = 1
+	}
+	out.WriteString(strings.Repeat("^", width))
+	out.WriteString("\n")
+
+	return out.String()
+}
+
+// ErrorList is a list of parse errors in source order, it implements error
+// so that a whole list can be returned by Parse.
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns nil for an empty list and the list itself otherwise.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// Render formats every error in the list with ParseError.Render.
+func (l ErrorList) Render(src string) string {
+	var out strings.Builder
+
+	for _, e := range l {
+		out.WriteString(e.Render(src))
+	}
+
+	return out.String()
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/Gonzih/go-interpreter/ast"
+	"github.com/Gonzih/go-interpreter/lexer"
+	"github.com/Gonzih/go-interpreter/token"
+)
+
+// OperatorKind tells where an operator stands relative to its operands.
+type OperatorKind int
+
+const (
+	PrefixOperator OperatorKind = iota
+	InfixOperator
+	PostfixOperator
+)
+
+// Operator declares an extra operator for a single parser, for example a
+// pipe "|>" or a range "..". Symbols are either punctuation or a word.
+type Operator struct {
+	Symbol string
+	Kind   OperatorKind
+	// Precedence is one of LOWEST+1 up to INDEX. For prefix operators it is
+	// the precedence their operand is parsed with.
+	Precedence    int
+	Associativity Associativity
+	// Node builds the expression for the operator, left is nil for prefix
+	// and right is nil for postfix operators. When Node is nil the parser
+	// builds a PrefixExpression, InfixExpression or PostfixExpression.
+	Node func(op token.Token, left, right ast.Expression) ast.Expression
+}
+
+// structural tokens carry grammar of their own and can not be redeclared
+// as operators
+var structural = map[token.TokenType]bool{
+	token.ASSIGN:    true,
+	token.COMMA:     true,
+	token.SEMICOLON: true,
+	token.COLON:     true,
+	token.LPAREN:    true,
+	token.RPAREN:    true,
+	token.LBRACE:    true,
+	token.RBRACE:    true,
+	token.LBRACKET:  true,
+	token.RBRACKET:  true,
+	token.FUNCTION:  true,
+	token.LET:       true,
+	token.TRUE:      true,
+	token.FALSE:     true,
+	token.IF:        true,
+	token.ELSE:      true,
+	token.RETURN:    true,
+	token.WHILE:     true,
+	token.FOR:       true,
+	token.BREAK:     true,
+	token.CONTINUE:  true,
+	token.SWITCH:    true,
+	token.CASE:      true,
+	token.DEFAULT:   true,
+	token.MATCH:     true,
+	token.ARROW:     true,
+	token.ELLIPSIS:  true,
+}
+
+// NewWithOperators creates a parser that understands ops on top of the
+// builtin grammar. The symbols are added to l, which must not have been
+// read from yet. A declared operator replaces a builtin one of the same
+// symbol and kind. When any of ops is rejected l is left unchanged.
+func NewWithOperators(l *lexer.Lexer, ops ...Operator) (*Parser, error) {
+	types := make([]token.TokenType, len(ops))
+
+	declared := make(map[token.TokenType]OperatorKind)
+	for i, op := range ops {
+		tt, err := lexer.SymbolType(op.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		types[i] = tt
+
+		if structural[tt] {
+			return nil, fmt.Errorf("operator %q is reserved", op.Symbol)
+		}
+
+		if op.Precedence <= LOWEST || op.Precedence > INDEX {
+			return nil, fmt.Errorf("operator %q: precedence %d out of range", op.Symbol, op.Precedence)
+		}
+
+		if op.Kind != PrefixOperator && op.Kind != InfixOperator && op.Kind != PostfixOperator {
+			return nil, fmt.Errorf("operator %q: unknown kind %d", op.Symbol, op.Kind)
+		}
+
+		// infix and postfix operators share the infix table
+		slot := op.Kind
+		if slot == PostfixOperator {
+			slot = InfixOperator
+		}
+		if kind, ok := declared[tt]; ok && kind == slot {
+			return nil, fmt.Errorf("operator %q declared twice", op.Symbol)
+		}
+		declared[tt] = slot
+	}
+
+	p := newParser(l)
+
+	for i, op := range ops {
+		tt := types[i]
+		if _, err := l.AddSymbol(op.Symbol); err != nil {
+			return nil, err
+		}
+
+		switch op.Kind {
+		case PrefixOperator:
+			p.registerPrefix(tt, p.prefixOperator(op))
+		case InfixOperator:
+			p.precedences[tt] = binding{op.Precedence, op.Associativity}
+			p.registerInfix(tt, p.infixOperator(op))
+		case PostfixOperator:
+			p.precedences[tt] = binding{op.Precedence, LeftAssoc}
+			p.registerInfix(tt, p.postfixOperator(op))
+		}
+	}
+
+	p.nextToken()
+	p.nextToken()
+
+	return p, nil
+}
+
+func (p *Parser) prefixOperator(op Operator) prefixParseFn {
+	return func() ast.Expression {
+		tok := p.curToken
+		p.nextToken()
+		right := p.parseExpression(op.Precedence)
+
+		if op.Node != nil {
+			return op.Node(tok, nil, right)
+		}
+
+		return &ast.PrefixExpression{Token: tok, Operator: tok.Literal, Right: right}
+	}
+}
+
+func (p *Parser) infixOperator(op Operator) infixParseFn {
+	return func(left ast.Expression) ast.Expression {
+		tok := p.curToken
+		precedence := p.rightPrecedence()
+		p.nextToken()
+		right := p.parseExpression(precedence)
+
+		if op.Node != nil {
+			return op.Node(tok, left, right)
+		}
+
+		return &ast.InfixExpression{Token: tok, Operator: tok.Literal, Left: left, Right: right}
+	}
+}
+
+func (p *Parser) postfixOperator(op Operator) infixParseFn {
+	return func(left ast.Expression) ast.Expression {
+		if op.Node != nil {
+			return op.Node(p.curToken, left, nil)
+		}
+
+		return &ast.PostfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
+	}
+}
@@ -12,12 +12,17 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = or +=
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // * or %
 	PREFIX      // -X or !X
+	POWER       // **
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 type (
@@ -25,16 +30,42 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
-var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+// Associativity decides how a chain of operators with the same precedence
+// groups: a - b - c is (a - b) - c, a ** b ** c is a ** (b ** c).
+type Associativity int
+
+const (
+	LeftAssoc Associativity = iota
+	RightAssoc
+)
+
+type binding struct {
+	precedence    int
+	associativity Associativity
+}
+
+var precedences = map[token.TokenType]binding{
+	token.ASSIGN:          {ASSIGN, RightAssoc},
+	token.PLUS_ASSIGN:     {ASSIGN, RightAssoc},
+	token.MINUS_ASSIGN:    {ASSIGN, RightAssoc},
+	token.ASTERISK_ASSIGN: {ASSIGN, RightAssoc},
+	token.SLASH_ASSIGN:    {ASSIGN, RightAssoc},
+	token.EQ:              {EQUALS, LeftAssoc},
+	token.NOT_EQ:          {EQUALS, LeftAssoc},
+	token.OR:              {OR, LeftAssoc},
+	token.AND:             {AND, LeftAssoc},
+	token.LT:              {LESSGREATER, LeftAssoc},
+	token.GT:              {LESSGREATER, LeftAssoc},
+	token.LT_EQ:           {LESSGREATER, LeftAssoc},
+	token.GT_EQ:           {LESSGREATER, LeftAssoc},
+	token.PLUS:            {SUM, LeftAssoc},
+	token.MINUS:           {SUM, LeftAssoc},
+	token.SLASH:           {PRODUCT, LeftAssoc},
+	token.ASTERISK:        {PRODUCT, LeftAssoc},
+	token.PERCENT:         {PRODUCT, LeftAssoc},
+	token.POWER:           {POWER, RightAssoc},
+	token.LPAREN:          {CALL, LeftAssoc},
+	token.LBRACKET:        {INDEX, LeftAssoc},
 }
 
 type Parser struct {
@@ -43,22 +74,53 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	errors ErrorList
+	// panicking is set by the first error of a statement, further errors
+	// are dropped until the parser synchronizes on the next statement.
+	// Statements leave their optional ";" to synchronize while panicking.
+	panicking bool
+	panicTok  token.Token
+	// number of lexer errors already copied into errors
+	lexErrors int
+	// number of loop bodies around the current token, reset to 0 inside of
+	// function literals so that break can not leave a function
+	loopDepth int
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+	// precedences starts as a copy of the package table, operators
+	// declared with NewWithOperators only change it for this parser
+	precedences map[token.TokenType]binding
+
+	comments ast.CommentMap
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := newParser(l)
 
 	p.nextToken()
 	p.nextToken()
+
+	return p
+}
+
+// newParser sets up the builtin grammar without reading any tokens, so that
+// operators can still be added to the lexer.
+func newParser(l *lexer.Lexer) *Parser {
+	p := &Parser{l: l, errors: ErrorList{}}
+
+	p.precedences = make(map[token.TokenType]binding, len(precedences))
+	for tt, b := range precedences {
+		p.precedences[tt] = b
+	}
+
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -69,9 +131,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.SWITCH, p.parseSwitchExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	// blocks after if and fn are consumed by parseBlockStatement directly,
+	// so a "{" only reaches the prefix table in expression position
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
@@ -80,20 +149,58 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	return p
 }
 
-func (p *Parser) Errors() []string {
+// Parse parses a whole program from src, the returned error is an
+// ErrorList when there were any parse errors.
+func Parse(src string) (*ast.Program, error) {
+	p := New(lexer.New(src))
+	program := p.ParseProgram()
+
+	return program, p.Errors().Err()
+}
+
+func (p *Parser) Errors() ErrorList {
 	return p.errors
 }
 
+func (p *Parser) addError(code ErrorCode, tok token.Token, expected []token.TokenType, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+
+	p.panicking = true
+	p.panicTok = tok
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:      tok.Pos,
+		Code:     code,
+		Found:    tok,
+		Expected: expected,
+		Msg:      fmt.Sprintf(format, a...),
+	})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf(`expected next token to be %q, got %q instead`,
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(ErrUnexpectedToken, p.peekToken, []token.TokenType{t},
+		"expected next token to be %q, got %q instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -106,6 +213,18 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	// lexical errors do not put the parser into panic mode, the lexer
+	// already produced a usable token for them
+	for _, e := range p.l.Errors()[p.lexErrors:] {
+		p.errors = append(p.errors, &ParseError{
+			Pos:   e.Pos,
+			Code:  ErrLexical,
+			Found: p.peekToken,
+			Msg:   e.Msg,
+		})
+	}
+	p.lexErrors = len(p.l.Errors())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -113,35 +232,123 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		// a stray "}" at the top level is simply skipped
+		stmt, _ := p.parseStatementRecovering()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 
 	return program
 }
 
+// parseStatementRecovering parses a statement and recovers from any error
+// inside of it. Statements that could not be built at all are replaced
+// with a BadStatement. The returned bool is true when the current token is
+// a "}" that is not part of the statement and has to be left for the
+// enclosing block.
+func (p *Parser) parseStatementRecovering() (ast.Statement, bool) {
+	start := p.curToken
+	stmt := p.parseStatement()
+
+	atBrace := false
+	if p.panicking {
+		atBrace = p.synchronize()
+		if stmt == nil {
+			stmt = &ast.BadStatement{Token: start}
+		}
+	}
+
+	if stmt != nil && len(start.Leading) > 0 {
+		if p.comments == nil {
+			p.comments = ast.CommentMap{}
+		}
+		p.comments[stmt] = ast.GroupComments(start.Leading)
+	}
+
+	return stmt, atBrace
+}
+
+// synchronize skips the remaining tokens of a broken statement, it stops
+// on a ";" or right before a statement keyword or a "}", so that the next
+// call to nextToken moves to the start of the next statement. A block it
+// enters, like the body after a broken if header, is skipped as a whole.
+func (p *Parser) synchronize() bool {
+	p.panicking = false
+
+	if (p.curTokenIs(token.RBRACE) || p.curTokenIs(token.DEDENT)) && p.curToken.Pos == p.panicTok.Pos {
+		return true
+	}
+
+	depth := 0
+	for depth > 0 || !p.curTokenIs(token.SEMICOLON) {
+		if p.curTokenIs(token.EOF) {
+			return false
+		}
+
+		if depth == 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE,
+				token.CASE, token.DEFAULT, token.RBRACE, token.DEDENT, token.EOF:
+				return false
+			}
+		}
+
+		p.nextToken()
+
+		switch p.curToken.Type {
+		case token.LBRACE, token.INDENT:
+			depth++
+		case token.RBRACE, token.DEDENT:
+			depth--
+		}
+	}
+
+	return false
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		if p.peekTokenIs(token.LPAREN) {
+			return p.parseForStatement()
+		}
+		return p.parseForInStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.SEMICOLON:
+		// empty statement
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
-	}
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeekName() {
+			return nil
+		}
+
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -151,7 +358,17 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if stmt.Pattern != nil {
+		// reported after the value, so that parsing goes on after it
+		if e := checkPattern(stmt.Pattern, false); e != nil {
+			p.addError(e.Code, e.Found, nil, "%s", e.Msg)
+			return nil
+		}
+	} else if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -176,47 +393,238 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	return false
 }
 
+// expectPeekName is expectPeek(token.IDENT) for a name being bound, with a
+// clearer error when the name is a reserved word.
+func (p *Parser) expectPeekName() bool {
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		return true
+	}
+
+	if token.IsKeyword(p.peekToken.Literal) {
+		// skipped, so that a statement keyword does not start a new statement
+		p.nextToken()
+		p.addError(ErrReservedName, p.curToken, []token.TokenType{token.IDENT},
+			"%q is a reserved word and can not be used as a name", p.curToken.Literal)
+		return false
+	}
+
+	p.peekError(token.IDENT)
+	return false
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	// e.g. invalid UTF-8, the lexer already reported it
+	if t == token.ILLEGAL && p.lexicalErrorIn(p.curToken) {
+		return
+	}
+
+	p.addError(ErrNoPrefixParseFn, p.curToken, nil,
+		"no prefix parse function for %s found", t)
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
+	if b, ok := p.precedences[p.peekToken.Type]; ok {
+		return b.precedence
 	}
 
 	return LOWEST
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
+	if b, ok := p.precedences[p.curToken.Type]; ok {
+		return b.precedence
 	}
 
 	return LOWEST
 }
 
+// rightPrecedence is the precedence the right operand of the current infix
+// operator is parsed with. Lowering it by one for right-associative
+// operators lets the operand absorb a following operator of the same level.
+func (p *Parser) rightPrecedence() int {
+	b, ok := p.precedences[p.curToken.Type]
+	if !ok {
+		return LOWEST
+	}
+
+	if b.associativity == RightAssoc {
+		return b.precedence - 1
+	}
+
+	return b.precedence
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
+	// a bare return gives null
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) ||
+		p.peekTokenIs(token.DEDENT) || p.peekTokenIs(token.EOF) {
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	// for (init; condition; post)
+	p.nextToken()
+	p.nextToken()
+
+	if !p.curTokenIs(token.SEMICOLON) {
+		if p.curTokenIs(token.LET) {
+			stmt.Init = p.parseLetStatement()
+		} else {
+			stmt.Init = p.parseExpressionStatement()
+		}
+
+		if stmt.Init == nil {
+			return nil
+		}
+
+		// the init statement consumes its ";" when there is one
+		if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Condition = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		stmt.Post = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForInStatement() ast.Statement {
+	stmt := &ast.ForInStatement{Token: p.curToken}
+
+	if !p.expectPeekName() {
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
+// parseLoopBody expects the start of a loop body next and parses the
+// block with break and continue allowed in it.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expectBlock() {
+		return nil
+	}
+
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+
+	return body
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+
+	if p.loopDepth == 0 {
+		p.addError(ErrOutsideLoop, tok, nil, "%s is not in a loop", tok.Literal)
+		return nil
+	}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -227,12 +635,12 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix, ok := p.prefixParseFns[p.curToken.Type]
 	if !ok {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return &ast.BadExpression{Token: p.curToken}
 	}
 
 	leftExp := prefix()
 
-	for !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.EOF) && precedence < p.peekPrecedence() {
+	for !p.panicking && !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.EOF) && precedence < p.peekPrecedence() {
 		infix, ok := p.infixParseFns[p.peekToken.Type]
 		if !ok {
 			return leftExp
@@ -253,10 +661,35 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if err != nil && p.lexicalErrorIn(p.curToken) {
+		// already reported by the lexer, keep the literal as a zero
+		return lit
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.addError(ErrInvalidInteger, p.curToken, nil,
+			"could not parse %q as integer", p.curToken.Literal)
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil && p.lexicalErrorIn(p.curToken) {
+		return lit
+	}
+	if err != nil {
+		p.addError(ErrInvalidFloat, p.curToken, nil,
+			"could not parse %q as float", p.curToken.Literal)
+		return &ast.BadExpression{Token: p.curToken}
 	}
 
 	lit.Value = value
@@ -264,6 +697,18 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// lexicalErrorIn reports whether the lexer found an error inside of tok.
+func (p *Parser) lexicalErrorIn(tok token.Token) bool {
+	for _, e := range p.errors {
+		offset := e.Pos.Offset - tok.Pos.Offset
+		if e.Code == ErrLexical && offset >= 0 && offset < len(tok.Literal) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -284,24 +729,49 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 
-	precedence := p.curPrecedence()
+	precedence := p.rightPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
 	return expression
 }
 
+// parseAssignExpression parses a right-associative assignment, a = b = c
+// is a = (b = c).
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(ErrInvalidTarget, p.curToken, nil,
+			"cannot assign to %s", target.String())
+		return &ast.BadExpression{Token: expression.Token}
+	}
+
+	precedence := p.rightPrecedence()
+	p.nextToken()
+	expression.Value = p.parseExpression(precedence)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	start := p.curToken
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return &ast.BadExpression{Token: start}
 	}
 
 	return exp
@@ -311,18 +781,18 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	exp := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: exp.Token}
 	}
 
 	p.nextToken()
 	exp.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return &ast.BadExpression{Token: exp.Token}
 	}
 
-	if !p.expectPeek(token.LBRACE) {
-		return nil
+	if !p.expectBlock() {
+		return &ast.BadExpression{Token: exp.Token}
 	}
 
 	exp.Consequence = p.parseBlockStatement()
@@ -330,8 +800,20 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
-		if !p.expectPeek(token.LBRACE) {
-			return nil
+		// an else if chain nests the next if in a block of its own
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			tok := p.curToken
+
+			exp.Alternative = &ast.BlockStatement{Token: tok, Statements: []ast.Statement{
+				&ast.ExpressionStatement{Token: tok, Expression: p.parseIfExpression()},
+			}}
+
+			return exp
+		}
+
+		if !p.expectBlock() {
+			return &ast.BadExpression{Token: exp.Token}
 		}
 
 		exp.Alternative = p.parseBlockStatement()
@@ -340,20 +822,144 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseSwitchExpression() ast.Expression {
+	exp := &ast.SwitchExpression{Token: p.curToken}
+
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		p.nextToken()
+		exp.Subject = p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.RPAREN) {
+			return &ast.BadExpression{Token: exp.Token}
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
+	p.nextToken()
+
+	var duplicate *ast.SwitchCase
+	hasDefault := false
+
+	for !p.curTokenIs(token.RBRACE) {
+		c := p.parseSwitchCase()
+		if c == nil {
+			return &ast.BadExpression{Token: exp.Token}
+		}
+
+		if c.Values == nil {
+			if hasDefault && duplicate == nil {
+				duplicate = c
+			}
+			hasDefault = true
+		}
+
+		exp.Cases = append(exp.Cases, c)
+	}
+
+	// a second default is still a well formed case, the cases after it
+	// were parsed as usual and only the switch as a whole is rejected
+	if duplicate != nil {
+		p.addError(ErrDuplicateDefault, duplicate.Token, nil, "multiple defaults in switch")
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
+	return exp
+}
+
+// parseSwitchCase parses a case or the default case starting at the
+// current token, up to the next case or the closing "}".
+func (p *Parser) parseSwitchCase() *ast.SwitchCase {
+	c := &ast.SwitchCase{Token: p.curToken}
+
+	switch p.curToken.Type {
+	case token.CASE:
+		c.Values = []ast.Expression{}
+		for {
+			p.nextToken()
+			c.Values = append(c.Values, p.parseExpression(LOWEST))
+
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	case token.DEFAULT:
+	default:
+		p.addError(ErrUnexpectedToken, p.curToken, []token.TokenType{token.CASE, token.DEFAULT, token.RBRACE},
+			"expected case or default, got %q instead", p.curToken.Type)
+		return nil
+	}
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	c.Body = &ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{}}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.CASE) && !p.curTokenIs(token.DEFAULT) &&
+		!p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		stmt, atBrace := p.parseStatementRecovering()
+
+		if stmt != nil {
+			c.Body.Statements = append(c.Body.Statements, stmt)
+		}
+
+		if !atBrace || !p.curTokenIs(token.RBRACE) {
+			p.nextToken()
+		}
+	}
+
+	return c
+}
+
+// expectBlock expects the start of a block next, either a "{" or a ":"
+// followed by an INDENT in indentation mode, see lexer.EnableIndentation.
+func (p *Parser) expectBlock() bool {
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.expectPeek(token.INDENT)
+	}
+
+	return p.expectPeek(token.LBRACE)
+}
+
+// parseBlockStatement parses a block starting at the current "{" or
+// INDENT, up to the matching "}" or DEDENT.
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	end := token.TokenType(token.RBRACE)
+	if p.curTokenIs(token.INDENT) {
+		end = token.DEDENT
+		// the same node as for the brace form, at the position of the INDENT
+		block.Token = token.Token{Type: token.LBRACE, Literal: "{", Pos: p.curToken.Pos}
+	}
+
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+	for !p.curTokenIs(end) && !p.curTokenIs(token.EOF) {
+		stmt, atBrace := p.parseStatementRecovering()
 
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 
-		p.nextToken()
+		// a stray "}" in an indented block is skipped
+		if !atBrace || !p.curTokenIs(end) {
+			p.nextToken()
+		}
+	}
+
+	if p.curTokenIs(token.EOF) {
+		p.addError(ErrUnexpectedToken, p.curToken, []token.TokenType{end},
+			"expected %q to close the block, got %q instead", end, p.curToken.Type)
 	}
 
 	return block
@@ -363,74 +969,238 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return &ast.BadExpression{Token: lit.Token}
+	}
 
-	if !p.expectPeek(token.LBRACE) {
-		return nil
+	if !p.expectBlock() {
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses the parameters of lit up to the ")":
+// required ones, then the ones with a default and finally a rest
+// parameter. It returns false if they are malformed.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if lit.Rest != nil {
+				p.addError(ErrInvalidParameter, p.curToken, nil, "only one rest parameter is allowed")
+				return false
+			}
+
+			if !p.expectPeekName() {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		} else {
+			if lit.Rest != nil {
+				p.addError(ErrInvalidParameter, p.peekToken, []token.TokenType{token.RPAREN},
+					"the rest parameter has to come last")
+				return false
+			}
+
+			if !p.expectPeekName() {
+				return false
+			}
+			param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			var value ast.Expression
+			if p.peekTokenIs(token.ASSIGN) {
+				p.nextToken()
+				p.nextToken()
+				value = p.parseExpression(LOWEST)
+
+				if lit.Defaults == nil {
+					lit.Defaults = make([]ast.Expression, len(lit.Parameters))
+				}
+			} else if lit.Defaults != nil {
+				p.addError(ErrInvalidParameter, param.Token, nil,
+					"parameter %s without a default follows one with a default", param.Value)
+				return false
+			}
+
+			lit.Parameters = append(lit.Parameters, param)
+			if lit.Defaults != nil {
+				lit.Defaults = append(lit.Defaults, value)
+			}
+		}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+		if !p.peekTokenIs(token.RPAREN) && !p.expectListComma(token.RPAREN) {
+			return false
+		}
 	}
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	return true
+}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+// expectListComma expects the comma between two list elements next, it
+// reports the end token as expected otherwise.
+func (p *Parser) expectListComma(end token.TokenType) bool {
+	if !p.peekTokenIs(token.COMMA) {
+		p.peekError(end)
+		return false
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
+	p.nextToken()
 
-	return identifiers
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+
+	if !p.parseCallArguments(exp) {
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+// parseCallArguments parses the arguments of a call up to the ")": the
+// positional ones, which can be spread with "...", followed by the named
+// ones. It returns false if they are malformed.
+func (p *Parser) parseCallArguments(exp *ast.CallExpression) bool {
+	exp.Arguments = []ast.Expression{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+
+		switch {
+		case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+			arg := &ast.NamedArgument{Token: p.curToken}
+			arg.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			for _, other := range exp.Named {
+				if other.Name.Value == arg.Name.Value {
+					p.addError(ErrInvalidArgument, arg.Token, nil, "argument %s is passed more than once", arg.Name.Value)
+					return false
+				}
+			}
+
+			p.nextToken()
+			p.nextToken()
+			arg.Value = p.parseExpression(LOWEST)
+			exp.Named = append(exp.Named, arg)
+		case len(exp.Named) > 0:
+			p.addError(ErrInvalidArgument, p.curToken, nil, "positional argument after named arguments")
+			return false
+		case p.curTokenIs(token.ELLIPSIS):
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			exp.Arguments = append(exp.Arguments, spread)
+		default:
+			exp.Arguments = append(exp.Arguments, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectListComma(token.RPAREN) {
+			return false
+		}
+	}
+
+	p.nextToken()
+
+	return true
+}
+
+// parseExpressionList parses comma separated expressions up to and
+// including the end token, a trailing comma is allowed. It returns nil if
+// the list is malformed.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
 
-	if p.peekTokenIs(token.RPAREN) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list
 	}
 
 	p.nextToken()
 
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if p.peekTokenIs(end) {
+			break
+		}
+
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return &ast.BadExpression{Token: array.Token}
+	}
+
+	return array
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return &ast.BadExpression{Token: hash.Token}
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBRACE) {
+			break
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return &ast.BadExpression{Token: hash.Token}
+	}
+
+	return hash
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
+	return exp
 }
@@ -2,10 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/Gonzih/go-interpreter/ast"
 	"github.com/Gonzih/go-interpreter/lexer"
+	"github.com/Gonzih/go-interpreter/token"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -268,6 +270,12 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 != 6;", 5, "!=", 6},
 		{"true == true;", true, "==", true},
 		{"true != false", true, "!=", false},
+		{"5 <= 6;", 5, "<=", 6},
+		{"5 >= 6;", 5, ">=", 6},
+		{"5 % 6;", 5, "%", 6},
+		{"5 ** 6;", 5, "**", 6},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 	}
 
 	for _, tt := range infixTests {
@@ -315,6 +323,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"[1, 2 * 3][fn(x){x}(0)]", "([1, (2 * 3)][fn(x)x(0)])"},
+		{"a[0][1]", "((a[0])[1])"},
+		{"f(x)[0]", "(f(x)[0])"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a || b || c", "((a || b) || c)"},
+		{"a && b && c", "((a && b) && c)"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a < b || a >= c && !d", "((a < b) || ((a >= c) && (!d)))"},
+		{"a + b % c * d", "(a + ((b % c) * d))"},
+		{"a % b <= c - d", "((a % b) <= (c - d))"},
+		{"x = a || b", "(x = (a || b))"},
+		{"-a % b", "((-a) % b)"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"a ** b * c", "((a ** b) * c)"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"2 ** -2", "(2 ** (-2))"},
+		{"a ** b[0]", "(a ** (b[0]))"},
+		{"a - b - c", "((a - b) - c)"},
+		{"a = b = c", "(a = (b = c))"},
 	}
 
 	for _, tt := range tests {
@@ -418,6 +450,35 @@ func TestIfElseExpression(t *testing.T) {
 	assert.NotNil(t, alternative)
 	assert.True(t, ok)
 	testIdentifier(t, alternative.Expression, "y")
+
+	assert.Equal(t, "if(x < y) xelse y", program.String())
+}
+
+func TestElseIfExpression(t *testing.T) {
+	input := `if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }`
+
+	program, err := Parse(input)
+	assert.NoError(t, err)
+	assert.Len(t, program.Statements, 1)
+	assert.Equal(t, "ifa 1else ifb 2else ifc 3else 4", program.String())
+
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	for _, name := range []string{"b", "c"} {
+		if !assert.NotNil(t, exp.Alternative) || !assert.Len(t, exp.Alternative.Statements, 1) {
+			t.FailNow()
+		}
+
+		stmt, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+		assert.True(t, ok)
+		exp, ok = stmt.Expression.(*ast.IfExpression)
+		if !assert.True(t, ok) {
+			t.FailNow()
+		}
+		testIdentifier(t, exp.Condition, name)
+	}
+
+	assert.Len(t, exp.Alternative.Statements, 1)
+	testIntegerLiteral(t, exp.Alternative.Statements[0].(*ast.ExpressionStatement).Expression, 4)
 }
 
 func TestFunctionLiteralParsing(t *testing.T) {
@@ -509,3 +570,1411 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, call.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, call.Arguments[2], 4, "+", 5)
 }
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	assert.Len(t, program.Statements, 1)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	assert.True(t, ok)
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	assert.True(t, ok)
+	if function == nil {
+		t.FailNow()
+	}
+
+	assert.Equal(t, "myFunction", function.Name)
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", `1:7: expected next token to be "=", got "INT" instead`},
+		{"let x = 5;\n  let = 10;", `2:7: expected next token to be "IDENT", got "=" instead`},
+		{"\n\n  +", `3:3: no prefix parse function for + found`},
+		{"99999999999999999999", `1:1: could not parse "99999999999999999999" as integer`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		assert.NotEmpty(t, p.Errors(), tt.input)
+		if len(p.Errors()) == 0 {
+			continue
+		}
+
+		assert.Equal(t, tt.expected, p.Errors()[0].Error())
+	}
+}
+
+func TestParseErrorFilename(t *testing.T) {
+	input := "let = 1;"
+
+	fset := token.NewFileSet()
+	l := lexer.NewFile(fset.AddFile("main.mk", len(input)), input)
+	p := New(l)
+	p.ParseProgram()
+
+	assert.NotEmpty(t, p.Errors())
+	assert.Equal(t, `main.mk:1:5: expected next token to be "IDENT", got "=" instead`, p.Errors()[0].Error())
+}
+
+func TestParseErrorDetails(t *testing.T) {
+	tests := []struct {
+		input         string
+		code          ErrorCode
+		foundType     token.TokenType
+		foundLiteral  string
+		expectedTypes []token.TokenType
+	}{
+		{"let x 5;", ErrUnexpectedToken, token.INT, "5", []token.TokenType{token.ASSIGN}},
+		{"if (x { 1 }", ErrUnexpectedToken, token.LBRACE, "{", []token.TokenType{token.RPAREN}},
+		{"+ 1", ErrNoPrefixParseFn, token.PLUS, "+", nil},
+		{"99999999999999999999", ErrInvalidInteger, token.INT, "99999999999999999999", nil},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.NotEmpty(t, p.Errors(), tt.input)
+		if len(p.Errors()) == 0 {
+			continue
+		}
+
+		err := p.Errors()[0]
+		assert.Equal(t, tt.code, err.Code, tt.input)
+		assert.Equal(t, tt.foundType, err.Found.Type, tt.input)
+		assert.Equal(t, tt.foundLiteral, err.Found.Literal, tt.input)
+		assert.Equal(t, tt.expectedTypes, err.Expected, tt.input)
+		assert.Equal(t, err.Found.Pos, err.Pos, tt.input)
+	}
+}
+
+func TestParse(t *testing.T) {
+	program, err := Parse("let x = 1 + 2;")
+	assert.NoError(t, err)
+	assert.Equal(t, "let x = (1 + 2);", program.String())
+
+	program, err = Parse("let x 1; let y 2;")
+	assert.NotNil(t, program)
+
+	list, ok := err.(ErrorList)
+	assert.True(t, ok, "error is not ErrorList, got %T", err)
+	assert.Len(t, list, 2)
+	assert.EqualError(t, err, `1:7: expected next token to be "=", got "INT" instead (and 1 more errors)`)
+}
+
+func TestParseErrorRender(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x 55;",
+			"1:7: expected next token to be \"=\", got \"INT\" instead\n" +
+				"let x 55;\n" +
+				"      ^^\n",
+		},
+		{
+			"let a = 1;\n\tlet = 2;\nlet b = 3;",
+			"2:6: expected next token to be \"IDENT\", got \"=\" instead\n" +
+				"\tlet = 2;\n" +
+				"\t    ^\n",
+		},
+		{
+			"let x",
+			"1:6: expected next token to be \"=\", got \"EOF\" instead\n" +
+				"let x\n" +
+				"     ^\n",
+		},
+		{
+			"let café 日本;",
+			"1:10: expected next token to be \"=\", got \"IDENT\" instead\n" +
+				"let café 日本;\n" +
+				"         ^^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		assert.Error(t, err, tt.input)
+		if err == nil {
+			continue
+		}
+
+		list := err.(ErrorList)
+		assert.Equal(t, tt.expected, list[0].Render(tt.input))
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let x 5;
+let y = (1 + 2;
+let z = fn(a) { a + };
+let ok = 10;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	assert.Len(t, errors, 3)
+	if len(errors) != 3 {
+		for _, e := range errors {
+			t.Log(e)
+		}
+		t.FailNow()
+	}
+
+	assert.Equal(t, `1:7: expected next token to be "=", got "INT" instead`, errors[0].Error())
+	assert.Equal(t, `2:15: expected next token to be ")", got ";" instead`, errors[1].Error())
+	assert.Equal(t, `3:21: no prefix parse function for } found`, errors[2].Error())
+
+	assert.Len(t, program.Statements, 4)
+	if len(program.Statements) != 4 {
+		t.FailNow()
+	}
+
+	assert.IsType(t, &ast.BadStatement{}, program.Statements[0])
+
+	testLetStatement(t, "y", program.Statements[1])
+	assert.IsType(t, &ast.BadExpression{}, program.Statements[1].(*ast.LetStatement).Value)
+
+	testLetStatement(t, "z", program.Statements[2])
+	function, ok := program.Statements[2].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	assert.True(t, ok)
+	if function != nil {
+		assert.Equal(t, "(a + <bad expression>)", function.Body.String())
+	}
+
+	testLetStatement(t, "ok", program.Statements[3])
+	testLiteralExpression(t, program.Statements[3].(*ast.LetStatement).Value, 10)
+}
+
+func TestErrorRecoveryErrorCounts(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors int
+	}{
+		{"let x 5; let = 10; let 838383;", 3},
+		{"add(1, (2 + 3, 4); add(5, 6);", 1},
+		{"fn(x) { let = 1; let y = 2; }", 1},
+		{"if (x) { 1", 1},
+		{"} let a = 1;", 1},
+		{"let a = ; let b = ; let c = 3;", 2},
+		{"if (x { 1 }; let y = 2", 1},
+		{"let x = fn(a b) { 1 }; let y = 2", 1},
+		{"if (x { if (y) { 1 } }; let z = ; let w = 1", 2},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		assert.Len(t, p.Errors(), tt.expectedErrors, "%s: %v", tt.input, p.Errors())
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello world";`, "hello world"},
+		{`"a\tb\n"`, "a\tb\n"},
+		{`"\u{263A}"`, "☺"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err)
+		assert.Len(t, program.Statements, 1)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		assert.True(t, ok, "exp not *ast.StringLiteral, got %T", stmt.Expression)
+		if ok {
+			assert.Equal(t, tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestStringLiteralRoundTrip(t *testing.T) {
+	inputs := []string{
+		`let name = "bob";`,
+		`let s = "line\nbreak\ttab \"quoted\" back\\slash";`,
+		`let u = "snow ☃";`,
+	}
+
+	for _, input := range inputs {
+		program, err := Parse(input)
+		assert.NoError(t, err)
+		assert.Equal(t, input, program.String())
+
+		again, err := Parse(program.String())
+		assert.NoError(t, err)
+		assert.Equal(t, program.String(), again.String())
+	}
+}
+
+func TestLexicalErrors(t *testing.T) {
+	input := "let s = \"open;\nlet x = 1;"
+
+	program, err := Parse(input)
+	assert.Error(t, err)
+
+	list := err.(ErrorList)
+	assert.Len(t, list, 1)
+	assert.Equal(t, ErrLexical, list[0].Code)
+	assert.Equal(t, "1:9: string literal not terminated", list[0].Error())
+
+	// the unterminated string still produced a statement
+	assert.Len(t, program.Statements, 2)
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	program, err := Parse(input)
+	assert.NoError(t, err)
+	assert.Len(t, program.Statements, 1)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	assert.True(t, ok, "exp not *ast.ArrayLiteral, got %T", stmt.Expression)
+	if !ok {
+		t.FailNow()
+	}
+
+	assert.Len(t, array.Elements, 3)
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	program, err := Parse("[]")
+	assert.NoError(t, err)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	assert.True(t, ok, "exp not *ast.ArrayLiteral, got %T", stmt.Expression)
+	if ok {
+		assert.Len(t, array.Elements, 0)
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	program, err := Parse(input)
+	assert.NoError(t, err)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	assert.True(t, ok, "exp not *ast.IndexExpression, got %T", stmt.Expression)
+	if !ok {
+		t.FailNow()
+	}
+
+	testIdentifier(t, indexExp.Left, "myArray")
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestParsingArrayErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2", `1:6: expected next token to be "]", got "EOF" instead`},
+		{"a[1", `1:4: expected next token to be "]", got "EOF" instead`},
+		{"[1 2]", `1:4: expected next token to be "]", got "INT" instead`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		assert.Error(t, err, tt.input)
+		if err != nil {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error())
+		}
+	}
+}
+
+func parseHashLiteral(t *testing.T, input string) *ast.HashLiteral {
+	program, err := Parse(input)
+	assert.NoError(t, err, input)
+	assert.Len(t, program.Statements, 1)
+	if err != nil || len(program.Statements) != 1 {
+		t.FailNow()
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	assert.True(t, ok, "exp not *ast.HashLiteral, got %T", stmt.Expression)
+	if !ok {
+		t.FailNow()
+	}
+
+	return hash
+}
+
+func TestParsingHashLiteralsStringKeys(t *testing.T) {
+	hash := parseHashLiteral(t, `{"one": 1, "two": 2, "three": 3}`)
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	assert.Len(t, hash.Pairs, len(expected))
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		assert.True(t, ok, "key is not *ast.StringLiteral, got %T", pair.Key)
+		if ok {
+			assert.Equal(t, expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	hash := parseHashLiteral(t, "{}")
+	assert.Len(t, hash.Pairs, 0)
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	hash := parseHashLiteral(t, `{"a": 1, true: 2, 3 + 4: fn(x){x}}`)
+
+	assert.Len(t, hash.Pairs, 3)
+	if len(hash.Pairs) != 3 {
+		t.FailNow()
+	}
+
+	testIntegerLiteral(t, hash.Pairs[0].Value, 1)
+	testBoolean(t, hash.Pairs[1].Key, true)
+	testIntegerLiteral(t, hash.Pairs[1].Value, 2)
+	testInfixExpression(t, hash.Pairs[2].Key, 3, "+", 4)
+	assert.IsType(t, &ast.FunctionLiteral{}, hash.Pairs[2].Value)
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a": 1,}`, `{"a": 1}`},
+		{`{"a": 1, "b": 2,}`, `{"a": 1, "b": 2}`},
+		{`{"a": {"b": {}}}`, `{"a": {"b": {}}}`},
+		{`{"a": {"b": 1,}, "c": [1, 2,],}`, `{"a": {"b": 1}, "c": [1, 2]}`},
+		{`{1: 0 + 1, 2: 10 - 8}[1]`, `({1: (0 + 1), 2: (10 - 8)}[1])`},
+		{`if (x) { {"a": 1} }`, `ifx {"a": 1}`},
+		{`fn() { {} }`, `fn(){}`},
+		{`f(1, 2,)`, `f(1, 2)`},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+}
+
+func TestParsingHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a" 1}`, `1:6: expected next token to be ":", got "INT" instead`},
+		{`{"a": 1 "b": 2}`, `1:9: expected next token to be "}", got "STRING" instead`},
+		{`{,}`, `1:2: no prefix parse function for , found`},
+		{`{"a": 1`, `1:8: expected next token to be "}", got "EOF" instead`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		assert.Error(t, err, tt.input)
+		if err != nil {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	program, err := Parse(`while (x < 10) { let x = x + 1; }`)
+	assert.NoError(t, err)
+	assert.Len(t, program.Statements, 1)
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	assert.True(t, ok, "stmt not *ast.WhileStatement, got %T", program.Statements[0])
+	if !ok {
+		t.FailNow()
+	}
+
+	testInfixExpression(t, stmt.Condition, "x", "<", 10)
+	assert.Len(t, stmt.Body.Statements, 1)
+	testLetStatement(t, "x", stmt.Body.Statements[0])
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input     string
+		init      string
+		condition string
+		post      string
+	}{
+		{"for (let i = 0; i < 10; i + 1) { i }", "let i = 0;", "(i < 10)", "(i + 1)"},
+		{"for (i; i < 10; i) { i }", "i", "(i < 10)", "i"},
+		{"for (; i < 10;) { i }", "", "(i < 10)", ""},
+		{"for (;;) { i }", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Len(t, program.Statements, 1, tt.input)
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		assert.True(t, ok, "stmt not *ast.ForStatement, got %T", program.Statements[0])
+		if !ok {
+			continue
+		}
+
+		str := func(n ast.Node) string {
+			if n == nil || reflect.ValueOf(n).IsNil() {
+				return ""
+			}
+			return n.String()
+		}
+
+		assert.Equal(t, tt.init, str(stmt.Init), tt.input)
+		assert.Equal(t, tt.condition, str(stmt.Condition), tt.input)
+		assert.Equal(t, tt.post, str(stmt.Post), tt.input)
+		assert.Len(t, stmt.Body.Statements, 1, tt.input)
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	program, err := Parse(`for x in [1, 2, 3] { x }`)
+	assert.NoError(t, err)
+	assert.Len(t, program.Statements, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	assert.True(t, ok, "stmt not *ast.ForInStatement, got %T", program.Statements[0])
+	if !ok {
+		t.FailNow()
+	}
+
+	testIdentifier(t, stmt.Variable, "x")
+	assert.Equal(t, "[1, 2, 3]", stmt.Iterable.String())
+	assert.Len(t, stmt.Body.Statements, 1)
+}
+
+func TestLoopStatementsString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (true) { break; }", "while (true) break;"},
+		{"for (let i = 0; i < 3; i) { continue }", "for (let i = 0; (i < 3); i) continue;"},
+		{"for x in xs { if (x) { break } }", "for x in xs ifx break;"},
+		{"while (a) { for (;;) { break; }; continue; }", "while (a) for (; ; ) break;continue;"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break is not in a loop"},
+		{"continue", "1:1: continue is not in a loop"},
+		{"if (x) { break; }", "1:10: break is not in a loop"},
+		{"while (x) { let f = fn() { break; }; }", "1:28: break is not in a loop"},
+		{"for x in xs { } break;", "1:17: break is not in a loop"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		assert.Error(t, err, tt.input)
+		if err == nil {
+			continue
+		}
+
+		list := err.(ErrorList)
+		assert.Len(t, list, 1, tt.input)
+		assert.Equal(t, ErrOutsideLoop, list[0].Code, tt.input)
+		assert.Equal(t, tt.expected, list[0].Error(), tt.input)
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while x { }", `1:7: expected next token to be "(", got "IDENT" instead`},
+		{"for (let i = 0 i) { }", `1:16: expected next token to be ";", got "IDENT" instead`},
+		{"for (;; i { }", `1:11: expected next token to be ")", got "{" instead`},
+		{"for x [1] { }", `1:7: expected next token to be "IN", got "[" instead`},
+		{"for 1 in xs { }", `1:5: expected next token to be "IDENT", got "INT" instead`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		assert.Error(t, err, tt.input)
+		if err != nil {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		target   string
+		value    string
+	}{
+		{"x = 5;", "=", "x", "5"},
+		{"x += 1", "+=", "x", "1"},
+		{"x -= y * 2", "-=", "x", "(y * 2)"},
+		{"x *= 3", "*=", "x", "3"},
+		{"x /= 4", "/=", "x", "4"},
+		{"a[0] = 1", "=", "(a[0])", "1"},
+		{"a[i][j] += f(1)", "+=", "((a[i])[j])", "f(1)"},
+		{"x = y = 3", "=", "x", "(y = 3)"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Len(t, program.Statements, 1, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		assert.True(t, ok, "exp not *ast.AssignExpression, got %T", stmt.Expression)
+		if !ok {
+			continue
+		}
+
+		assert.Equal(t, tt.operator, exp.Operator, tt.input)
+		assert.Equal(t, tt.target, exp.Target.String(), tt.input)
+		assert.Equal(t, tt.value, exp.Value.String(), tt.input)
+	}
+}
+
+func TestAssignPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1 + 2 * 3", "(x = (1 + (2 * 3)))"},
+		{"x = y == z", "(x = (y == z))"},
+		{"a = b += c -= d", "(a = (b += (c -= d)))"},
+		{"a[i + 1] = -b", "((a[(i + 1)]) = (-b))"},
+		{"f(x = 1, y)", "f((x = 1), y)"},
+		{"let z = x = 2;", "let z = (x = 2);"},
+		{"for (let i = 0; i < 10; i += 1) { x = i }", "for (let i = 0; (i < 10); (i += 1)) (x = i)"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+}
+
+func TestInvalidAssignTargets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2", "1:3: cannot assign to 1"},
+		{"a + b = c", "1:7: cannot assign to (a + b)"},
+		{"f() += 1", "1:5: cannot assign to f()"},
+		{"x -\n  y = 3", "2:5: cannot assign to (x - y)"},
+		{`"s" = 1`, `1:5: cannot assign to "s"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		assert.Error(t, err, tt.input)
+		if err == nil {
+			continue
+		}
+
+		list := err.(ErrorList)
+		assert.Len(t, list, 1, tt.input)
+		assert.Equal(t, ErrInvalidTarget, list[0].Code, tt.input)
+		assert.Equal(t, tt.expected, list[0].Error(), tt.input)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	ops := []Operator{
+		{Symbol: "|>", Kind: InfixOperator, Precedence: ASSIGN + 1,
+			Node: func(op token.Token, left, right ast.Expression) ast.Expression {
+				return &ast.CallExpression{Token: op, Function: right, Arguments: []ast.Expression{left}}
+			}},
+		{Symbol: "..", Kind: InfixOperator, Precedence: LESSGREATER},
+		{Symbol: "in", Kind: InfixOperator, Precedence: EQUALS},
+		{Symbol: "::", Kind: InfixOperator, Precedence: SUM, Associativity: RightAssoc},
+		{Symbol: "not", Kind: PrefixOperator, Precedence: PREFIX},
+		{Symbol: "!", Kind: PostfixOperator, Precedence: CALL},
+		{Symbol: "?", Kind: PostfixOperator, Precedence: CALL},
+		{Symbol: "+", Kind: InfixOperator, Precedence: PRODUCT},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f |> g", "g(f(x))"},
+		{"1 + 2 |> f", "f((1 + 2))"},
+		{"1 .. n + 1", "(1 .. (n + 1))"},
+		{"x in 1 .. 10", "(x in (1 .. 10))"},
+		{"a :: b :: c", "(a :: (b :: c))"},
+		{"not a == b", "((not a) == b)"},
+		{"5! * 2", "((5!) * 2)"},
+		{"-a!", "(-(a!))"},
+		{"n! * 2", "((n!) * 2)"},
+		{"x? * 2", "((x?) * 2)"},
+		{"empty? && x", "((empty?) && x)"},
+		{"!a", "(!a)"},
+		{"a + b * c", "((a + b) * c)"},
+		{"for x in xs { x }", "for x in xs x"},
+	}
+
+	for _, tt := range tests {
+		p, err := NewWithOperators(lexer.New(tt.input), ops...)
+		assert.NoError(t, err)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+
+	// operators are declared per parser
+	p := New(lexer.New("a |> b"))
+	p.ParseProgram()
+	assert.NotEmpty(t, p.Errors())
+}
+
+func TestOperatorErrors(t *testing.T) {
+	tests := []struct {
+		ops      []Operator
+		expected string
+	}{
+		{[]Operator{{Symbol: "", Kind: InfixOperator, Precedence: SUM}}, "empty operator symbol"},
+		{[]Operator{{Symbol: "|1", Kind: InfixOperator, Precedence: SUM}}, `invalid operator symbol "|1"`},
+		{[]Operator{{Symbol: "//", Kind: InfixOperator, Precedence: PRODUCT}}, `operator symbol "//" starts a comment`},
+		{[]Operator{{Symbol: ":", Kind: InfixOperator, Precedence: SUM}}, `operator ":" is reserved`},
+		{[]Operator{{Symbol: "if", Kind: PrefixOperator, Precedence: SUM}}, `operator "if" is reserved`},
+		{[]Operator{{Symbol: "switch", Kind: PrefixOperator, Precedence: SUM}}, `operator "switch" is reserved`},
+		{[]Operator{{Symbol: "case", Kind: PrefixOperator, Precedence: SUM}}, `operator "case" is reserved`},
+		{[]Operator{{Symbol: "default", Kind: PrefixOperator, Precedence: SUM}}, `operator "default" is reserved`},
+		{[]Operator{{Symbol: "match", Kind: PrefixOperator, Precedence: SUM}}, `operator "match" is reserved`},
+		{[]Operator{{Symbol: "=>", Kind: InfixOperator, Precedence: SUM}}, `operator "=>" is reserved`},
+		{[]Operator{{Symbol: "...", Kind: PrefixOperator, Precedence: SUM}}, `operator "..." is reserved`},
+		{[]Operator{{Symbol: "|>", Kind: InfixOperator, Precedence: LOWEST}}, `operator "|>": precedence 1 out of range`},
+		{[]Operator{{Symbol: "|>", Kind: 7, Precedence: SUM}}, `operator "|>": unknown kind 7`},
+		{[]Operator{
+			{Symbol: "|>", Kind: InfixOperator, Precedence: SUM},
+			{Symbol: "|>", Kind: PostfixOperator, Precedence: SUM},
+		}, `operator "|>" declared twice`},
+	}
+
+	for _, tt := range tests {
+		_, err := NewWithOperators(lexer.New(""), tt.ops...)
+		if assert.Error(t, err) {
+			assert.Equal(t, tt.expected, err.Error())
+		}
+	}
+
+	// the operators before the rejected one are not added either
+	l := lexer.New("n! |> f")
+	_, err := NewWithOperators(l,
+		Operator{Symbol: "!", Kind: PostfixOperator, Precedence: CALL},
+		Operator{Symbol: "|>", Kind: 7, Precedence: SUM},
+	)
+	assert.Error(t, err)
+	for _, expected := range []token.Token{
+		{Type: token.IDENT, Literal: "n!"},
+		{Type: token.ILLEGAL, Literal: "|"},
+		{Type: token.GT, Literal: ">"},
+	} {
+		tok := l.NextToken()
+		assert.Equal(t, expected.Type, tok.Type)
+		assert.Equal(t, expected.Literal, tok.Literal)
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0x1F", int64(31)},
+		{"0o17", int64(15)},
+		{"0b101", int64(5)},
+		{"1_000", int64(1000)},
+		{"3.14", 3.14},
+		{"1e-3", 0.001},
+		{"1_0.5e1", 105.0},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch expected := tt.expected.(type) {
+		case int64:
+			lit, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if assert.True(t, ok, tt.input) {
+				assert.Equal(t, expected, lit.Value, tt.input)
+			}
+		case float64:
+			lit, ok := stmt.Expression.(*ast.FloatLiteral)
+			if assert.True(t, ok, tt.input) {
+				assert.Equal(t, expected, lit.Value, tt.input)
+				assert.Equal(t, tt.input, lit.String())
+			}
+		}
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// malformed numbers are only reported by the lexer
+		{"0x + 1", "1:1: hexadecimal literal has no digits"},
+		{"1e + 1", "1:1: exponent has no digits"},
+		{"99999999999999999999", `1:1: could not parse "99999999999999999999" as integer`},
+		{"1e999", `1:1: could not parse "1e999" as float`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		errors, ok := err.(ErrorList)
+		if assert.True(t, ok, tt.input) && assert.Len(t, errors, 1, tt.input) {
+			assert.Equal(t, tt.expected, errors[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	program, err := Parse("let x = \xff;\nlet y = 1;")
+	assert.Error(t, err)
+
+	// reported once by the lexer, not again as a missing prefix function
+	list := err.(ErrorList)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "1:9: invalid UTF-8 encoding (byte 0xff)", list[0].Error())
+	assert.Len(t, program.Statements, 2)
+}
+
+func TestReservedNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let if = 1;", `1:5: "if" is a reserved word and can not be used as a name`},
+		{"let fn = 1;", `1:5: "fn" is a reserved word and can not be used as a name`},
+		{"for true in xs { }", `1:5: "true" is a reserved word and can not be used as a name`},
+		{"let 1 = 1;", `1:5: expected next token to be "IDENT", got "INT" instead`},
+		{"let let = 1;", `1:5: "let" is a reserved word and can not be used as a name`},
+		{"let return = 1;", `1:5: "return" is a reserved word and can not be used as a name`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		list, ok := err.(ErrorList)
+		if assert.True(t, ok, tt.input) && assert.Len(t, list, 1, tt.input) {
+			assert.Equal(t, tt.expected, list[0].Error(), tt.input)
+		}
+	}
+
+	program, err := Parse("let empty? = fn(x) { x == 0 }; let x1 = empty?(1);")
+	assert.NoError(t, err)
+	assert.Equal(t, "let empty? = fn(x)(x == 0);let x1 = empty?(1);", program.String())
+}
+
+func TestStatementComments(t *testing.T) {
+	input := `// add adds two numbers
+let add = fn(a, b) {
+	// the sum
+	a + b // not a doc comment
+};
+
+// unrelated
+
+// result
+add(1, 2);
+let x = 1;`
+
+	program, err := Parse(input)
+	assert.NoError(t, err)
+	assert.Len(t, program.Statements, 3)
+
+	texts := func(stmt ast.Statement) []string {
+		var out []string
+		for _, g := range program.Comments[stmt] {
+			out = append(out, g.Text())
+		}
+		return out
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	body := let.Value.(*ast.FunctionLiteral).Body
+
+	assert.Equal(t, []string{"add adds two numbers"}, texts(let))
+	assert.Equal(t, []string{"the sum"}, texts(body.Statements[0]))
+	assert.Equal(t, []string{"unrelated", "result"}, texts(program.Statements[1]))
+	assert.Nil(t, texts(program.Statements[2]))
+
+	// comments do not change the parsed program
+	assert.Equal(t, "let add = fn(a, b)(a + b);add(1, 2)let x = 1;", program.String())
+}
+
+func TestSemicolonInsertion(t *testing.T) {
+	tests := []struct {
+		lines     string
+		semicolon string
+	}{
+		{"let x = 5\nlet y = x\nx + y", "let x = 5; let y = x; x + y;"},
+		{"let x = a\n(b)", "let x = a; (b);"},
+		{"let x = a\n-b", "let x = a; -b;"},
+		{"add(\n  1,\n  2\n)\n[\n  1\n]", "add(1, 2); [1];"},
+		{"if (x) {\n  y\n}\nelse {\n  z\n}", "if (x) { y; } else { z; };"},
+		{"let f = fn(x)\n{\n  return x\n}", "let f = fn(x) { return x; };"},
+		{"fn() {\n  return\n}", "fn() { return; };"},
+		{"while (x) {\n  x -= 1\n  continue\n}", "while (x) { x -= 1; continue; };"},
+		{"x // note\ny", "x; y;"},
+		{"x;;\n\ny", "x; y;"},
+	}
+
+	for _, tt := range tests {
+		lines, err := Parse(tt.lines)
+		assert.NoError(t, err, tt.lines)
+		semicolon, err := Parse(tt.semicolon)
+		assert.NoError(t, err, tt.semicolon)
+
+		if lines != nil && semicolon != nil {
+			assert.Equal(t, semicolon.String(), lines.String(), tt.lines)
+			assert.Equal(t, len(semicolon.Statements), len(lines.Statements), tt.lines)
+		}
+	}
+}
+
+func TestIndentedBlocks(t *testing.T) {
+	tests := []struct {
+		indented string
+		braces   string
+	}{
+		{
+			"if (x):\n    y\nelse:\n    z",
+			"if (x) { y } else { z }",
+		},
+		{
+			"let f = fn(a, b):\n  let c = a + b\n  return c\nf(1, 2)",
+			"let f = fn(a, b) { let c = a + b; return c; }; f(1, 2);",
+		},
+		{
+			"fn():\n\tif (x):\n\t\treturn\n\ty\nz",
+			"fn() { if (x) { return; } y; }; z;",
+		},
+		{
+			"while (x):\n  x -= 1\n  if (x < 3):\n    break",
+			"while (x) { x -= 1; if (x < 3) { break; } }",
+		},
+		{
+			"let h = fn():\n  {\"a\": [1,\n    2]}",
+			"let h = fn() { {\"a\": [1, 2]} };",
+		},
+		{
+			"fn() { x }\nif (y):\n  fn():\n    z",
+			"fn() { x }; if (y) { fn() { z } };",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.indented)
+		l.EnableIndentation()
+		p := New(l)
+		indented := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		braces, err := Parse(tt.braces)
+		assert.NoError(t, err, tt.braces)
+
+		assert.Equal(t, braces.String(), indented.String(), tt.indented)
+		assert.Equal(t, nodeShape(braces), nodeShape(indented), tt.indented)
+	}
+}
+
+// nodeShape lists the node types and the token types of node in tree
+// order, leaving out literals and positions.
+func nodeShape(node ast.Node) []string {
+	var shape []string
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if v.IsNil() {
+				shape = append(shape, "nil")
+				return
+			}
+			if v.Kind() == reflect.Ptr {
+				shape = append(shape, v.Type().String())
+			}
+			walk(v.Elem())
+		case reflect.Struct:
+			if tok, ok := v.Interface().(token.Token); ok {
+				shape = append(shape, string(tok.Type))
+				return
+			}
+			for i := 0; i < v.NumField(); i++ {
+				walk(v.Field(i))
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(node))
+
+	return shape
+}
+
+func TestIndentedBlockErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (x):\ny", `2:1: expected next token to be "INDENT", got "IDENT" instead`},
+		{"fn():\n  x\n   }\n  y", `3:4: no prefix parse function for } found`},
+		{"if (x):\n  y\n \tz", "3:1: indentation mixes tabs and spaces"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		l.EnableIndentation()
+		p := New(l)
+		p.ParseProgram()
+
+		if assert.NotEmpty(t, p.Errors(), tt.input) {
+			assert.Equal(t, tt.expected, p.Errors()[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestSwitchExpression(t *testing.T) {
+	input := `switch (x) {
+case 1, 2:
+	let y = x
+	y * 2
+case 3:
+default:
+	0
+}`
+
+	program, err := Parse(input)
+	assert.NoError(t, err)
+	assert.Len(t, program.Statements, 1)
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SwitchExpression)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+
+	testIdentifier(t, exp.Subject, "x")
+	if !assert.Len(t, exp.Cases, 3) {
+		t.FailNow()
+	}
+
+	assert.Len(t, exp.Cases[0].Values, 2)
+	testIntegerLiteral(t, exp.Cases[0].Values[0], 1)
+	testIntegerLiteral(t, exp.Cases[0].Values[1], 2)
+	assert.Len(t, exp.Cases[0].Body.Statements, 2)
+	assert.Len(t, exp.Cases[1].Values, 1)
+	assert.Empty(t, exp.Cases[1].Body.Statements)
+	assert.Nil(t, exp.Cases[2].Values)
+	assert.Len(t, exp.Cases[2].Body.Statements, 1)
+
+	assert.Equal(t, "switch (x) { case 1, 2: let y = x;(y * 2) case 3:  default: 0 }", program.String())
+}
+
+func TestSwitchConditions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"switch { case x > 1: a; case x < 0: b }", "switch { case (x > 1): a case (x < 0): b }"},
+		{"switch {}", "switch { }"},
+		{"let y = switch (f(x)) { default: 1 } + 1", "let y = (switch (f(x)) { default: 1 } + 1);"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+
+	// a switch is not a loop
+	_, err := Parse("switch (x) { case 1: if (y) { break } }")
+	assert.EqualError(t, err, "1:31: break is not in a loop")
+}
+
+func TestSwitchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"switch (x) { default: 1; default: 2 }", "1:26: multiple defaults in switch"},
+		{"switch (x) { 1 }", `1:14: expected case or default, got "INT" instead`},
+		{"switch (x) { case 1 2 }", `1:21: expected next token to be ":", got "INT" instead`},
+		{"switch x { }", `1:8: expected next token to be "{", got "IDENT" instead`},
+		{"switch (x) { case 1: let 1; case 2: y }; z", `1:26: expected next token to be "IDENT", got "INT" instead`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if assert.Error(t, err, tt.input) {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error(), tt.input)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match value {
+	0 => "zero",
+	-1.5 => "negative",
+	[x, ...rest] => rest,
+	{"k": v, 1: [_, true]} => v,
+	n if n > 10 => n,
+	_ => value,
+}`
+
+	program, err := Parse(input)
+	assert.NoError(t, err)
+	if !assert.Len(t, program.Statements, 1) {
+		t.FailNow()
+	}
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if !assert.True(t, ok) || !assert.Len(t, exp.Arms, 6) {
+		t.FailNow()
+	}
+	testIdentifier(t, exp.Subject, "value")
+
+	literal, ok := exp.Arms[0].Pattern.(*ast.LiteralPattern)
+	assert.True(t, ok)
+	testIntegerLiteral(t, literal.Value, 0)
+
+	literal, ok = exp.Arms[1].Pattern.(*ast.LiteralPattern)
+	assert.True(t, ok)
+	assert.Equal(t, "-1.5", literal.String())
+
+	array, ok := exp.Arms[2].Pattern.(*ast.ArrayPattern)
+	if assert.True(t, ok) && assert.Len(t, array.Elements, 1) && assert.NotNil(t, array.Rest) {
+		assert.IsType(t, &ast.BindingPattern{}, array.Elements[0])
+		assert.Equal(t, "rest", array.Rest.Name.Value)
+	}
+	testIdentifier(t, exp.Arms[2].Body, "rest")
+
+	hash, ok := exp.Arms[3].Pattern.(*ast.HashPattern)
+	if assert.True(t, ok) && assert.Len(t, hash.Pairs, 2) {
+		assert.Equal(t, `"k"`, hash.Pairs[0].Key.String())
+		assert.IsType(t, &ast.BindingPattern{}, hash.Pairs[0].Value)
+		testIntegerLiteral(t, hash.Pairs[1].Key, 1)
+		assert.IsType(t, &ast.ArrayPattern{}, hash.Pairs[1].Value)
+	}
+
+	guard, ok := exp.Arms[4].Pattern.(*ast.GuardPattern)
+	if assert.True(t, ok) {
+		assert.IsType(t, &ast.BindingPattern{}, guard.Pattern)
+		testInfixExpression(t, guard.Guard, "n", ">", 10)
+	}
+
+	assert.IsType(t, &ast.WildcardPattern{}, exp.Arms[5].Pattern)
+}
+
+func TestMatchString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`match x { 0 => "zero", _ => "many" }`,
+			`match x { 0 => "zero", _ => "many" }`,
+		},
+		{
+			"match f(x) { [a, [b, c], ...] => a + b, [...r] => r, }",
+			"match f(x) { [a, [b, c], ...] => (a + b), [...r] => r }",
+		},
+		{
+			`match h { {"a": -1, true: {}} => 1, {} => 2, n if n == {} => 3 }`,
+			`match h { {"a": -1, true: {}} => 1, {} => 2, n if (n == {}) => 3 }`,
+		},
+		{
+			"let y = match x {}",
+			"let y = match x {  };",
+		},
+		{
+			"match x { [..._] => 1, _ if x => match x { _ => 2 } }",
+			"match x { [...] => 1, _ if x => match x { _ => 2 } }",
+		},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+
+		// the string parses back to the same match
+		program, err = Parse(tt.expected)
+		assert.NoError(t, err, tt.expected)
+		assert.Equal(t, tt.expected, program.String(), tt.expected)
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		code     ErrorCode
+	}{
+		{"match x { _ => 1, 2 => 2 }", "1:19: unreachable match arm, _ before it matches any value", ErrUnreachableArm},
+		{"match x { n => 1, _ => 2, m => 3 }", "1:19: unreachable match arm, n before it matches any value", ErrUnreachableArm},
+		{"match x { n if n => 1, 2 => 2 }", "", ""},
+		{"match x { a + 1 => 1 }", `1:13: expected next token to be "=>", got "+" instead`, ErrUnexpectedToken},
+		{"match x { let => 1 }", `1:11: expected a pattern, got "LET" instead`, ErrInvalidPattern},
+		{"match x { [...a, b] => 1 }", "1:16: the rest pattern has to come last", ErrInvalidPattern},
+		{"match x { {k: v} => 1 }", `1:12: expected a string, integer or boolean key, got "IDENT" instead`, ErrInvalidPattern},
+		{"match x { 1 => 1 2 => 2 }", `1:18: expected next token to be ",", got "INT" instead`, ErrUnexpectedToken},
+		{"match x { 1 => 1", `1:17: expected next token to be ",", got "EOF" instead`, ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if tt.expected == "" {
+			assert.NoError(t, err, tt.input)
+			continue
+		}
+
+		if assert.Error(t, err, tt.input) {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error(), tt.input)
+			assert.Equal(t, tt.code, err.(ErrorList)[0].Code, tt.input)
+		}
+	}
+}
+
+func TestDestructuringLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = pair;", "let [a, b] = pair;"},
+		{"let {name, age} = user", "let {name, age} = user;"},
+		{`let {"first": f, 2: [x, _]} = h`, `let {"first": f, 2: [x, _]} = h;`},
+		{"let [head, ...tail] = xs", "let [head, ...tail] = xs;"},
+		{"let [[a, b], {c, ...others}, ...] = f()", "let [[a, b], {c, ...others}, ...] = f();"},
+		{"let {} = x; let [] = y", "let {} = x;let [] = y;"},
+		{`let {"a": a} = {"a": 1}`, `let {a} = {"a": 1};`},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if assert.True(t, ok, tt.input) {
+			assert.Nil(t, stmt.Name, tt.input)
+			assert.NotNil(t, stmt.Pattern, tt.input)
+		}
+	}
+
+	program, err := Parse("let [a, {b}, ...c] = x")
+	assert.NoError(t, err)
+	pattern := program.Statements[0].(*ast.LetStatement).Pattern.(*ast.ArrayPattern)
+	if assert.Len(t, pattern.Elements, 2) {
+		assert.IsType(t, &ast.BindingPattern{}, pattern.Elements[0])
+		hash := pattern.Elements[1].(*ast.HashPattern)
+		if assert.Len(t, hash.Pairs, 1) {
+			assert.Equal(t, `"b"`, hash.Pairs[0].Key.String())
+			assert.Equal(t, "b", hash.Pairs[0].Value.(*ast.BindingPattern).Name.Value)
+		}
+	}
+	assert.Equal(t, "c", pattern.Rest.Name.Value)
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		code     ErrorCode
+	}{
+		{"let [a, a] = x", "1:9: a is bound more than once in the pattern", ErrDuplicateName},
+		{"let [a, {b: [a]}] = x", `1:10: expected a string, integer or boolean key, got "IDENT" instead`, ErrInvalidPattern},
+		{"let [a, {\"b\": [a]}] = x", "1:16: a is bound more than once in the pattern", ErrDuplicateName},
+		{"let [a, ...a] = x", "1:12: a is bound more than once in the pattern", ErrDuplicateName},
+		{"let {a, ...r, b} = x", "1:13: the rest pattern has to come last", ErrInvalidPattern},
+		{"let [1, a] = x", "1:6: literal 1 can only be used in a match pattern", ErrInvalidPattern},
+		{"let [a b] = x", `1:8: expected next token to be ",", got "IDENT" instead`, ErrUnexpectedToken},
+		{"let [a] x", `1:9: expected next token to be "=", got "IDENT" instead`, ErrUnexpectedToken},
+		{"match x { [a, a] => 1 }", "1:15: a is bound more than once in the pattern", ErrDuplicateName},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if assert.Error(t, err, tt.input) {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error(), tt.input)
+			assert.Equal(t, tt.code, err.(ErrorList)[0].Code, tt.input)
+		}
+	}
+
+	// parsing goes on after the statement
+	_, err := Parse("let [a, a] = x; let b = ;")
+	assert.Len(t, err.(ErrorList), 2)
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2, ...rest) { a }", "fn(a, b = 2, ...rest)a"},
+		{"fn(a = 1 + 2, b = [a]) { }", "fn(a = (1 + 2), b = [a])"},
+		{"fn(...args) { args }", "fn(...args)args"},
+		{"fn(a, b,) { }", "fn(a, b)"},
+		{"fn(\n  a,\n  b = 1,\n) { }", "fn(a, b = 1)"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+
+	program, err := Parse("fn(a, b = 2, c = d, ...rest) { }")
+	assert.NoError(t, err)
+	fl := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if assert.Len(t, fl.Parameters, 3) && assert.Len(t, fl.Defaults, 3) {
+		assert.Nil(t, fl.Defaults[0])
+		testIntegerLiteral(t, fl.Defaults[1], 2)
+		testIdentifier(t, fl.Defaults[2], "d")
+	}
+	testIdentifier(t, fl.Rest, "rest")
+
+	// plain parameters have no defaults
+	program, err = Parse("fn(a, b) { }")
+	assert.NoError(t, err)
+	fl = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	assert.Nil(t, fl.Defaults)
+	assert.Nil(t, fl.Rest)
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		code     ErrorCode
+	}{
+		{"fn(a = 1, b) { }", "1:11: parameter b without a default follows one with a default", ErrInvalidParameter},
+		{"fn(...a, ...b) { }", "1:10: only one rest parameter is allowed", ErrInvalidParameter},
+		{"fn(...a, b) { }", "1:10: the rest parameter has to come last", ErrInvalidParameter},
+		{"fn(1) { }", `1:4: expected next token to be "IDENT", got "INT" instead`, ErrUnexpectedToken},
+		{"fn(a b) { }", `1:6: expected next token to be ")", got "IDENT" instead`, ErrUnexpectedToken},
+		{"fn(if) { }", `1:4: "if" is a reserved word and can not be used as a name`, ErrReservedName},
+		{"fn(...) { }", `1:7: expected next token to be "IDENT", got ")" instead`, ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if assert.Error(t, err, tt.input) && assert.Len(t, err.(ErrorList), 1, tt.input) {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error(), tt.input)
+			assert.Equal(t, tt.code, err.(ErrorList)[0].Code, tt.input)
+		}
+	}
+}
+
+func TestCallArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(1, b: 3)", "f(1, b: 3)"},
+		{"f(...xs)", "f(...xs)"},
+		{"f(a, ...g(b), c: d + 1, e: {})", "f(a, ...g(b), c: (d + 1), e: {})"},
+		{"f({a: 1})", "f({a: 1})"},
+		{"f(\n  x: 1,\n  y: 2,\n)", "f(x: 1, y: 2)"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, program.String(), tt.input)
+	}
+
+	program, err := Parse("f(1, ...xs, b: 3)")
+	assert.NoError(t, err)
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if assert.Len(t, call.Arguments, 2) && assert.Len(t, call.Named, 1) {
+		testIntegerLiteral(t, call.Arguments[0], 1)
+		spread, ok := call.Arguments[1].(*ast.SpreadExpression)
+		if assert.True(t, ok) {
+			testIdentifier(t, spread.Value, "xs")
+		}
+		assert.Equal(t, "b", call.Named[0].Name.Value)
+		testIntegerLiteral(t, call.Named[0].Value, 3)
+	}
+}
+
+func TestCallArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		code     ErrorCode
+	}{
+		{"f(a: 1, 2)", "1:9: positional argument after named arguments", ErrInvalidArgument},
+		{"f(a: 1, ...xs)", "1:9: positional argument after named arguments", ErrInvalidArgument},
+		{"f(a: 1, a: 2)", "1:9: argument a is passed more than once", ErrInvalidArgument},
+		{"f(1 2)", `1:5: expected next token to be ")", got "INT" instead`, ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if assert.Error(t, err, tt.input) && assert.Len(t, err.(ErrorList), 1, tt.input) {
+			assert.Equal(t, tt.expected, err.(ErrorList)[0].Error(), tt.input)
+			assert.Equal(t, tt.code, err.(ErrorList)[0].Code, tt.input)
+		}
+	}
+}
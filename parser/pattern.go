@@ -0,0 +1,317 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/Gonzih/go-interpreter/ast"
+	"github.com/Gonzih/go-interpreter/token"
+)
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
+	// the first arm matching any value, the arms after it can not match
+	var catchAll ast.Pattern
+	var problem *ParseError
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		start := p.curToken
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return &ast.BadExpression{Token: exp.Token}
+		}
+
+		if problem == nil {
+			problem = checkPattern(arm.Pattern, true)
+		}
+		if problem == nil && catchAll != nil {
+			problem = patternError(ErrUnreachableArm, start,
+				"unreachable match arm, %s before it matches any value", catchAll.String())
+		}
+		if catchAll == nil && matchesAll(arm.Pattern) {
+			catchAll = arm.Pattern
+		}
+
+		exp.Arms = append(exp.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return &ast.BadExpression{Token: exp.Token}
+		}
+	}
+
+	p.nextToken()
+
+	// a bad pattern or an unreachable arm does not stop the arms from
+	// being read, the first problem found rejects the match after its "}"
+	if problem != nil {
+		p.addError(problem.Code, problem.Found, nil, "%s", problem.Msg)
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
+	return exp
+}
+
+func patternError(code ErrorCode, tok token.Token, format string, a ...interface{}) *ParseError {
+	return &ParseError{Pos: tok.Pos, Code: code, Found: tok, Msg: fmt.Sprintf(format, a...)}
+}
+
+// checkPattern returns an error for the first name bound twice in
+// pattern. Literal patterns are only allowed when the pattern is
+// refutable, like in a match that falls through to the next arm.
+func checkPattern(pattern ast.Pattern, refutable bool) *ParseError {
+	names := map[string]bool{}
+
+	var check func(pattern ast.Pattern) *ParseError
+	check = func(pattern ast.Pattern) *ParseError {
+		switch pattern := pattern.(type) {
+		case *ast.BindingPattern:
+			return bind(names, pattern.Name)
+		case *ast.RestPattern:
+			if pattern.Name != nil {
+				return bind(names, pattern.Name)
+			}
+		case *ast.LiteralPattern:
+			if !refutable {
+				return patternError(ErrInvalidPattern, pattern.Token,
+					"literal %s can only be used in a match pattern", pattern.String())
+			}
+		case *ast.GuardPattern:
+			return check(pattern.Pattern)
+		case *ast.ArrayPattern:
+			for _, el := range pattern.Elements {
+				if e := check(el); e != nil {
+					return e
+				}
+			}
+			if pattern.Rest != nil {
+				return check(pattern.Rest)
+			}
+		case *ast.HashPattern:
+			for _, pair := range pattern.Pairs {
+				if e := check(pair.Value); e != nil {
+					return e
+				}
+			}
+			if pattern.Rest != nil {
+				return check(pattern.Rest)
+			}
+		}
+
+		return nil
+	}
+
+	return check(pattern)
+}
+
+func bind(names map[string]bool, name *ast.Identifier) *ParseError {
+	if names[name.Value] {
+		return patternError(ErrDuplicateName, name.Token, "%s is bound more than once in the pattern", name.Value)
+	}
+	names[name.Value] = true
+
+	return nil
+}
+
+// matchesAll reports whether pattern matches any value.
+func matchesAll(pattern ast.Pattern) bool {
+	switch pattern.(type) {
+	case *ast.WildcardPattern, *ast.BindingPattern:
+		return true
+	}
+
+	return false
+}
+
+// parseMatchArm parses "pattern [if guard] => body" starting at the
+// current token.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		guard := &ast.GuardPattern{Token: p.curToken, Pattern: arm.Pattern}
+
+		p.nextToken()
+		guard.Guard = p.parseExpression(LOWEST)
+		arm.Pattern = guard
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+
+	return arm
+}
+
+// parsePattern parses the pattern starting at the current token, it
+// reports an error and returns nil when there is none.
+func (p *Parser) parsePattern() ast.Pattern {
+	tok := p.curToken
+
+	switch tok.Type {
+	case token.IDENT:
+		if tok.Literal == "_" {
+			return &ast.WildcardPattern{Token: tok}
+		}
+
+		return &ast.BindingPattern{Token: tok, Name: &ast.Identifier{Token: tok, Value: tok.Literal}}
+	case token.MINUS:
+		if p.peekTokenIs(token.INT) || p.peekTokenIs(token.FLOAT) {
+			p.nextToken()
+			value := &ast.PrefixExpression{Token: tok, Operator: tok.Literal, Right: p.parseLiteral()}
+
+			return &ast.LiteralPattern{Token: tok, Value: value}
+		}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+
+	if value := p.parseLiteral(); value != nil {
+		return &ast.LiteralPattern{Token: tok, Value: value}
+	}
+
+	p.addError(ErrInvalidPattern, tok, nil, "expected a pattern, got %q instead", tok.Type)
+	return nil
+}
+
+// parseLiteral parses the integer, float, string or boolean literal at
+// the current token, it returns nil for any other token.
+func (p *Parser) parseLiteral() ast.Expression {
+	switch p.curToken.Type {
+	case token.INT:
+		return p.parseIntegerLiteral()
+	case token.FLOAT:
+		return p.parseFloatLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	}
+
+	return nil
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken, Elements: []ast.Pattern{}}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			pattern.Rest = p.parseRestPattern(token.RBRACKET)
+			if pattern.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+// parseRestPattern parses "..." and the optional name after it, the rest
+// has to come last before end.
+func (p *Parser) parseRestPattern(end token.TokenType) *ast.RestPattern {
+	rest := &ast.RestPattern{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		if p.curToken.Literal != "_" {
+			rest.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+	}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.addError(ErrInvalidPattern, p.peekToken, []token.TokenType{end},
+			"the rest pattern has to come last")
+		return nil
+	}
+
+	return rest
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken, Pairs: []ast.HashPatternPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			pattern.Rest = p.parseRestPattern(token.RBRACE)
+			if pattern.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		// {name} is short for {"name": name}
+		if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE)) {
+			key := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			pattern.Pairs = append(pattern.Pairs, ast.HashPatternPair{Key: key, Value: p.parsePattern()})
+
+			if p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+			}
+			continue
+		}
+
+		key := p.parseLiteral()
+		if key == nil || p.curTokenIs(token.FLOAT) {
+			p.addError(ErrInvalidPattern, p.curToken, nil,
+				"expected a string, integer or boolean key, got %q instead", p.curToken.Type)
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+		pattern.Pairs = append(pattern.Pairs, ast.HashPatternPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/Gonzih/go-interpreter/evaluator"
 	"github.com/Gonzih/go-interpreter/lexer"
+	"github.com/Gonzih/go-interpreter/object"
 	"github.com/Gonzih/go-interpreter/parser"
 )
 
@@ -13,9 +15,10 @@ const PROMPT = ">> "
 
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
 
 	for {
-		fmt.Print(PROMPT)
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -27,17 +30,18 @@ func Start(in io.Reader, out io.Writer) {
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+			printParseErrors(out, line, p.Errors())
 			continue
 		}
 
-		io.WriteString(out, program.String())
-		io.WriteString(out, "\n")
+		evaluated := evaluator.Eval(program, env)
+		if evaluated != nil {
+			io.WriteString(out, evaluated.Inspect())
+			io.WriteString(out, "\n")
+		}
 	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		fmt.Fprintf(out, "\t%s\n", msg)
-	}
+func printParseErrors(out io.Writer, line string, errors parser.ErrorList) {
+	io.WriteString(out, errors.Render(line))
 }
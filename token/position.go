@@ -0,0 +1,209 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Position is a human readable source location, Line and Column are 1-based
+// and Offset is the 0-based byte offset into the file. The lexer counts
+// columns in runes.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (pos Position) IsValid() bool { return pos.Line > 0 }
+
+// String formats the position as file:line:column, the file name is
+// omitted when it is empty and "-" is used for invalid positions.
+func (pos Position) String() string {
+	s := pos.Filename
+
+	if pos.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+}
+
+// Pos is a compact position inside of a FileSet, it can be converted into
+// a Position with FileSet.Position. The zero value is NoPos.
+type Pos int
+
+const NoPos Pos = 0
+
+func (p Pos) IsValid() bool { return p != NoPos }
+
+// File holds the line table of a single source file added to a FileSet.
+type File struct {
+	set  *FileSet
+	name string
+	base int
+	size int
+	// offsets of the first byte of each line, lines[0] is always 0
+	lines []int
+	// the runes longer than one byte, so that columns count runes
+	wide []wideRune
+}
+
+// wideRune is a rune longer than one byte at offset, extra adds up the
+// bytes beyond the first of it and of all the wide runes before it.
+type wideRune struct {
+	offset int
+	extra  int
+}
+
+func (f *File) Name() string { return f.name }
+func (f *File) Base() int    { return f.base }
+func (f *File) Size() int    { return f.size }
+func (f *File) LineCount() int {
+	return len(f.lines)
+}
+
+// SetSize grows the file to size, for sources that are read incrementally.
+// Only the file added last to its set can grow.
+func (f *File) SetSize(size int) {
+	if size < f.size {
+		panic(fmt.Sprintf("file size can not shrink from %d to %d", f.size, size))
+	}
+
+	if n := len(f.set.files); f.set.files[n-1] != f {
+		panic(fmt.Sprintf("file %q is not the last file of its set", f.name))
+	}
+
+	f.set.base += size - f.size
+	f.size = size
+}
+
+// AddLine records the offset of a new line start, offsets have to be added
+// in increasing order and smaller or repeated offsets are ignored.
+func (f *File) AddLine(offset int) {
+	if n := len(f.lines); (n == 0 || f.lines[n-1] < offset) && offset < f.size {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// AddRune records a rune of size bytes at offset, runes have to be added
+// in increasing order. Only runes longer than one byte need to be added
+// for Position to count columns in runes, like the lexer does.
+func (f *File) AddRune(offset, size int) {
+	if size <= 1 {
+		return
+	}
+
+	extra := size - 1
+	if n := len(f.wide); n > 0 {
+		if f.wide[n-1].offset >= offset {
+			return
+		}
+		extra += f.wide[n-1].extra
+	}
+
+	f.wide = append(f.wide, wideRune{offset: offset, extra: extra})
+}
+
+// extraBefore returns the bytes beyond the first of the wide runes before
+// offset.
+func (f *File) extraBefore(offset int) int {
+	i := sort.Search(len(f.wide), func(i int) bool { return f.wide[i].offset >= offset }) - 1
+	if i < 0 {
+		return 0
+	}
+
+	return f.wide[i].extra
+}
+
+// Pos converts a file offset into a FileSet position.
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > f.size {
+		panic(fmt.Sprintf("invalid file offset %d (size %d)", offset, f.size))
+	}
+
+	return Pos(f.base + offset)
+}
+
+// Offset converts a FileSet position into a file offset.
+func (f *File) Offset(p Pos) int {
+	offset := int(p) - f.base
+	if offset < 0 || offset > f.size {
+		panic(fmt.Sprintf("invalid Pos value %d (base %d, size %d)", p, f.base, f.size))
+	}
+
+	return offset
+}
+
+// Position resolves an offset inside of the file using its line table.
+// Column counts runes for the runes added with AddRune and bytes for the
+// rest of the line.
+func (f *File) Position(offset int) Position {
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+
+	pos := Position{Filename: f.name, Offset: offset}
+	if i >= 0 {
+		pos.Line = i + 1
+		pos.Column = offset - f.lines[i] + 1 - (f.extraBefore(offset) - f.extraBefore(f.lines[i]))
+	}
+
+	return pos
+}
+
+// FileSet maps Pos values of several files to their positions, each file
+// occupies the range [base, base+size] of the set.
+type FileSet struct {
+	base  int
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+func (s *FileSet) Base() int { return s.base }
+
+func (s *FileSet) AddFile(filename string, size int) *File {
+	f := &File{set: s, name: filename, base: s.base, size: size, lines: []int{0}}
+
+	// +1 so that the EOF position of a file is still distinct from the
+	// first position of the next one
+	s.base += size + 1
+	s.files = append(s.files, f)
+
+	return f
+}
+
+// File returns the file containing p, or nil if there is none.
+func (s *FileSet) File(p Pos) *File {
+	if !p.IsValid() {
+		return nil
+	}
+
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i < 0 {
+		return nil
+	}
+
+	f := s.files[i]
+	if int(p) > f.base+f.size {
+		return nil
+	}
+
+	return f
+}
+
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(f.Offset(p))
+	}
+
+	return Position{}
+}
@@ -1,13 +1,17 @@
 package token
 
+import "unicode"
+
 const (
 	// Special
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
 	// Identifiers + literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
 
 	// Operators
 	ASSIGN   = "="
@@ -16,22 +20,45 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 
 	EQ     = "=="
 	NOT_EQ = "!="
 
-	LT = "<"
-	GT = ">"
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
+
+	AND = "&&"
+	OR  = "||"
+
+	ARROW    = "=>"
+	ELLIPSIS = "..."
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
+	LBRACKET = "["
+	RBRACKET = "]"
+
+	// Layout, only emitted by the lexer in indentation mode
+	INDENT = "INDENT"
+	DEDENT = "DEDENT"
+
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -40,6 +67,15 @@ const (
 	ELSE     = "ELSE"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	SWITCH   = "SWITCH"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
+	MATCH    = "MATCH"
 )
 
 type TokenType string
@@ -47,18 +83,64 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+	// Leading holds the comments between the previous token and this one
+	// that do not trail the previous token, Trailing the comments after
+	// this token that start on its line.
+	Leading  []Comment
+	Trailing []Comment
+}
+
+// Comment is a single comment including its markers, e.g. "// note",
+// "/* note */" or a "#!" line at the start of a file.
+type Comment struct {
+	Pos  Position
+	Text string
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"return": RETURN,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
-	"==":     EQ,
-	"!=":     NOT_EQ,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"if":       IF,
+	"return":   RETURN,
+	"else":     ELSE,
+	"true":     TRUE,
+	"false":    FALSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"switch":   SWITCH,
+	"case":     CASE,
+	"default":  DEFAULT,
+	"match":    MATCH,
+}
+
+// IsKeyword reports whether s is a reserved word.
+func IsKeyword(s string) bool {
+	_, ok := keywords[s]
+	return ok
+}
+
+// IsIdentifier reports whether s is a valid identifier or keyword:
+//
+//	identifier = ( letter | "_" ) { letter | digit | "_" } [ "?" | "!" ]
+//
+// where letter and digit are Unicode letters and decimal digits. The
+// trailing "?" or "!" is meant for predicates and mutators, like empty?.
+func IsIdentifier(s string) bool {
+	for i, ch := range s {
+		switch {
+		case unicode.IsLetter(ch) || ch == '_':
+		case unicode.IsDigit(ch) && i > 0:
+		case (ch == '?' || ch == '!') && i > 0 && i == len(s)-1:
+		default:
+			return false
+		}
+	}
+
+	return s != ""
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,136 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      Position
+		expected string
+	}{
+		{Position{}, "-"},
+		{Position{Filename: "a.mk"}, "a.mk"},
+		{Position{Line: 3, Column: 7}, "3:7"},
+		{Position{Filename: "a.mk", Line: 3, Column: 7}, "a.mk:3:7"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.pos.String())
+	}
+}
+
+func TestFileSetPosition(t *testing.T) {
+	fset := NewFileSet()
+
+	// "ab\ncd\n"
+	a := fset.AddFile("a.mk", 6)
+	a.AddLine(3)
+	a.AddLine(6)
+
+	// "x\n\ny"
+	b := fset.AddFile("b.mk", 4)
+	b.AddLine(2)
+	b.AddLine(3)
+
+	assert.Equal(t, 2, a.LineCount())
+	assert.Equal(t, 3, b.LineCount())
+
+	tests := []struct {
+		pos      Pos
+		expected Position
+	}{
+		{a.Pos(0), Position{"a.mk", 0, 1, 1}},
+		{a.Pos(1), Position{"a.mk", 1, 1, 2}},
+		{a.Pos(4), Position{"a.mk", 4, 2, 2}},
+		{a.Pos(6), Position{"a.mk", 6, 2, 4}},
+		{b.Pos(0), Position{"b.mk", 0, 1, 1}},
+		{b.Pos(2), Position{"b.mk", 2, 2, 1}},
+		{b.Pos(3), Position{"b.mk", 3, 3, 1}},
+		{NoPos, Position{}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, fset.Position(tt.pos), "%d", tt.pos)
+	}
+
+	assert.Equal(t, a, fset.File(a.Pos(6)))
+	assert.Equal(t, b, fset.File(b.Pos(0)))
+	assert.Nil(t, fset.File(Pos(fset.Base())))
+}
+
+func TestFileSetSize(t *testing.T) {
+	fset := NewFileSet()
+	a := fset.AddFile("a.mk", 2)
+	b := fset.AddFile("b.mk", 0)
+
+	b.AddLine(3)
+	b.SetSize(5)
+	b.AddLine(3)
+
+	assert.Equal(t, 5, b.Size())
+	assert.Equal(t, 2, b.LineCount())
+	assert.Equal(t, b.Base()+6, fset.Base())
+	assert.Equal(t, Position{"b.mk", 4, 2, 2}, fset.Position(b.Pos(4)))
+
+	assert.Panics(t, func() { b.SetSize(4) })
+	assert.Panics(t, func() { a.SetSize(3) })
+}
+
+func TestFileRuneColumns(t *testing.T) {
+	fset := NewFileSet()
+
+	// "é✓x\nπy"
+	f := fset.AddFile("a.mk", 10)
+	f.AddRune(0, 2)
+	f.AddRune(2, 3)
+	f.AddRune(5, 1)
+	f.AddLine(6)
+	f.AddRune(6, 2)
+	f.AddRune(6, 2)
+
+	assert.Equal(t, Position{"a.mk", 2, 1, 2}, fset.Position(f.Pos(2)))
+	assert.Equal(t, Position{"a.mk", 5, 1, 3}, fset.Position(f.Pos(5)))
+	assert.Equal(t, Position{"a.mk", 6, 2, 1}, fset.Position(f.Pos(6)))
+	assert.Equal(t, Position{"a.mk", 8, 2, 2}, fset.Position(f.Pos(8)))
+	assert.Equal(t, Position{"a.mk", 9, 2, 3}, fset.Position(f.Pos(9)))
+}
+
+func TestIsIdentifier(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"x", true},
+		{"x1", true},
+		{"add2", true},
+		{"user_id_2", true},
+		{"_", true},
+		{"_tmp", true},
+		{"empty?", true},
+		{"push!", true},
+		{"café", true},
+		{"let", true},
+		{"", false},
+		{"1x", false},
+		{"?", false},
+		{"!x", false},
+		{"a?b", false},
+		{"a??", false},
+		{"a?!", false},
+		{"a-b", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, IsIdentifier(tt.input), tt.input)
+	}
+}
+
+func TestIsKeyword(t *testing.T) {
+	assert.True(t, IsKeyword("let"))
+	assert.True(t, IsKeyword("continue"))
+	assert.False(t, IsKeyword("x"))
+	assert.False(t, IsKeyword("Let"))
+}
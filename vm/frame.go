@@ -0,0 +1,21 @@
+package vm
+
+import (
+	"github.com/Gonzih/go-interpreter/code"
+	"github.com/Gonzih/go-interpreter/object"
+)
+
+type Frame struct {
+	cl *object.Closure
+	ip int
+	// stack pointer value before the call, locals live right above it
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,830 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Gonzih/go-interpreter/code"
+	"github.com/Gonzih/go-interpreter/compiler"
+	"github.com/Gonzih/go-interpreter/object"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+var (
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+	Null  = &object.Null{}
+)
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	// always points to the next free slot, top of the stack is stack[sp-1]
+	sp int
+
+	globals []object.Object
+
+	frames      []*Frame
+	framesIndex int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals: make([]object.Object, GlobalsSize),
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore creates a VM sharing the globals store, used by the
+// REPL to keep bindings between lines.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+
+	return vm
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("frame overflow")
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// LastPoppedStackElem returns the result of the last expression statement.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			n := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip++
+
+			for i := 0; i < n; i++ {
+				if err := vm.push(vm.stack[vm.sp-n]); err != nil {
+					return err
+				}
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual:
+			if err := vm.executeComparison(op); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.executeBangOperator(); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpIfLocalSet:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			frame := vm.currentFrame()
+			if vm.stack[frame.basePointer+int(localIndex)] != nil {
+				frame.ip = pos - 1
+			}
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			global := vm.globals[globalIndex]
+			if global == nil {
+				return fmt.Errorf("global %d is not set", globalIndex)
+			}
+
+			if err := vm.push(global); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			local := vm.stack[frame.basePointer+int(localIndex)]
+			if local == nil {
+				return fmt.Errorf("local %d is not set", localIndex)
+			}
+
+			if err := vm.push(local); err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure.Free[freeIndex]); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			if err := vm.callClosure(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpCallArgs:
+			namesIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.callClosureArgs(int(namesIndex)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// a return at the top level ends the program with its value
+			if vm.framesIndex == 1 {
+				vm.stack[vm.sp] = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			// -1 also drops the closure that was called
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				vm.stack[vm.sp] = Null
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(array); err != nil {
+				return err
+			}
+
+		case code.OpConcat:
+			numArrays := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := []object.Object{}
+			for _, value := range vm.stack[vm.sp-numArrays : vm.sp] {
+				spread, err := object.Spread(value)
+				if err != nil {
+					return err
+				}
+				elements = append(elements, spread...)
+			}
+			vm.sp = vm.sp - numArrays
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := object.SetIndex(left, index, value); err != nil {
+				return err
+			}
+
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure); err != nil {
+				return err
+			}
+
+		case code.OpIter:
+			iterable := vm.pop()
+
+			iterator, ok := object.NewIterator(iterable)
+			if !ok {
+				return fmt.Errorf("not iterable: %s", iterable.Type())
+			}
+
+			if err := vm.push(iterator); err != nil {
+				return err
+			}
+
+		case code.OpMatch:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			pattern := vm.constants[constIndex].(*object.Pattern)
+			if err := vm.executeMatch(pattern, vm.pop()); err != nil {
+				return err
+			}
+
+		case code.OpDestructure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			pattern := vm.constants[constIndex].(*object.Pattern)
+			bound, err := object.Destructure(pattern.Pattern, vm.pop())
+			if err != nil {
+				return err
+			}
+
+			for _, v := range bound {
+				if err := vm.push(v); err != nil {
+					return err
+				}
+			}
+
+		case code.OpIterNext:
+			if err := vm.executeIterNext(); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+
+	return o
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return vm.executeBinaryIntegerOperation(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeBinaryStringOperation(op, left, right)
+	}
+
+	return fmt.Errorf("unsupported types for binary operation: %s %s",
+		left.Type(), right.Type())
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	var result int64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftValue / rightValue
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftValue % rightValue
+	case code.OpPow:
+		if rightValue < 0 {
+			return fmt.Errorf("negative exponent: %d", rightValue)
+		}
+		result = object.Pow(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown integer operator: %d", op)
+	}
+
+	return vm.push(&object.Integer{Value: result})
+}
+
+// executeBinaryFloatOperation handles floats and integers mixed with
+// floats, the result is always a float.
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Object) error {
+	leftValue, _ := object.Number(left)
+	rightValue, _ := object.Number(right)
+
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftValue / rightValue
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = math.Mod(leftValue, rightValue)
+	case code.OpPow:
+		result = math.Pow(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
+	if op != code.OpAdd {
+		return fmt.Errorf("unknown string operator: %d", op)
+	}
+
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	return vm.push(&object.String{Value: leftValue + rightValue})
+}
+
+func (vm *VM) executeComparison(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+		return vm.executeIntegerComparison(op, left, right)
+	}
+
+	if object.IsNumber(left) && object.IsNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ && (op == code.OpEqual || op == code.OpNotEqual) {
+		equal := left.(*object.String).Value == right.(*object.String).Value
+		return vm.push(nativeBoolToBooleanObject(equal == (op == code.OpEqual)))
+	}
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(right == left))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(right != left))
+	default:
+		return fmt.Errorf("unknown operator: %d (%s %s)",
+			op, left.Type(), right.Type())
+	}
+}
+
+func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparison(op code.Opcode, left, right object.Object) error {
+	leftValue, _ := object.Number(left)
+	rightValue, _ := object.Number(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+
+	switch operand {
+	case True:
+		return vm.push(False)
+	case False:
+		return vm.push(True)
+	case Null:
+		return vm.push(True)
+	default:
+		return vm.push(False)
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	if f, ok := operand.(*object.Float); ok {
+		return vm.push(&object.Float{Value: -f.Value})
+	}
+
+	if operand.Type() != object.INTEGER_OBJ {
+		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+	}
+
+	value := operand.(*object.Integer).Value
+	return vm.push(&object.Integer{Value: -value})
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return fmt.Errorf("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(pair.Value)
+}
+
+func (vm *VM) executeArrayIndex(left, index object.Object) error {
+	elements := left.(*object.Array).Elements
+	i := index.(*object.Integer).Value
+
+	if i < 0 || i >= int64(len(elements)) {
+		return vm.push(Null)
+	}
+
+	return vm.push(elements[i])
+}
+
+func (vm *VM) executeIterNext() error {
+	iterator := vm.pop().(*object.Iterator)
+
+	item, ok := iterator.Next()
+	if !ok {
+		return vm.push(False)
+	}
+
+	if err := vm.push(item); err != nil {
+		return err
+	}
+
+	return vm.push(True)
+}
+
+func (vm *VM) executeMatch(pattern *object.Pattern, value object.Object) error {
+	bound, ok := object.Match(pattern.Pattern, value)
+	if !ok {
+		return vm.push(False)
+	}
+
+	for _, v := range bound {
+		if err := vm.push(v); err != nil {
+			return err
+		}
+	}
+
+	return vm.push(True)
+}
+
+func (vm *VM) callClosure(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	cl, ok := callee.(*object.Closure)
+	if !ok {
+		return fmt.Errorf("calling non-function")
+	}
+
+	fn := cl.Fn
+	if numArgs != fn.NumParameters || fn.Required != fn.NumParameters || fn.Rest {
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp -= numArgs
+
+		return vm.bindArguments(cl, args, nil, nil)
+	}
+
+	return vm.enterClosure(cl, numArgs)
+}
+
+// callClosureArgs calls the closure below an array of the positional
+// arguments and the values of the named arguments in the names constant.
+func (vm *VM) callClosureArgs(namesIndex int) error {
+	names := vm.constants[namesIndex].(*object.Array)
+	numNamed := len(names.Elements)
+
+	named := make([]object.Object, numNamed)
+	copy(named, vm.stack[vm.sp-numNamed:vm.sp])
+	args, err := object.Spread(vm.stack[vm.sp-numNamed-1])
+	if err != nil {
+		return err
+	}
+	vm.sp -= numNamed + 1
+
+	cl, ok := vm.stack[vm.sp-1].(*object.Closure)
+	if !ok {
+		return fmt.Errorf("calling non-function")
+	}
+
+	nameList := make([]string, numNamed)
+	for i, name := range names.Elements {
+		nameList[i] = name.(*object.String).Value
+	}
+
+	return vm.bindArguments(cl, args, nameList, named)
+}
+
+// bindArguments pushes the parameters of cl bound to the arguments and
+// calls it, a parameter left out is not set so that cl runs its default.
+func (vm *VM) bindArguments(cl *object.Closure, args []object.Object, names []string, named []object.Object) error {
+	bound, err := object.BindArguments(cl.Fn.Parameters, cl.Fn.Required, cl.Fn.Rest, args, names, named)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range bound {
+		if err := vm.push(value); err != nil {
+			return err
+		}
+	}
+
+	return vm.enterClosure(cl, len(bound))
+}
+
+// enterClosure runs cl with its numArgs parameters on top of the stack.
+func (vm *VM) enterClosure(cl *object.Closure, numArgs int) error {
+	frame := NewFrame(cl, vm.sp-numArgs)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	// arguments already sit in the first local slots, reserve the rest
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	// clear what is left in the other slots, they are not set yet
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+func nativeBoolToBooleanObject(native bool) *object.Boolean {
+	if native {
+		return True
+	}
+
+	return False
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
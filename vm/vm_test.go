@@ -0,0 +1,522 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/Gonzih/go-interpreter/compiler"
+	"github.com/Gonzih/go-interpreter/lexer"
+	"github.com/Gonzih/go-interpreter/object"
+	"github.com/Gonzih/go-interpreter/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		assert.NoError(t, err, tt.input)
+		if err != nil {
+			t.FailNow()
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		assert.NoError(t, err, tt.input)
+		if err != nil {
+			t.FailNow()
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		assert.True(t, ok, "%s: object is not Integer, got %T (%+v)", input, actual, actual)
+		if ok {
+			assert.Equal(t, int64(expected), integer.Value, input)
+		}
+	case float64:
+		float, ok := actual.(*object.Float)
+		assert.True(t, ok, "%s: object is not Float, got %T (%+v)", input, actual, actual)
+		if ok {
+			assert.Equal(t, expected, float.Value, input)
+		}
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		assert.True(t, ok, "%s: object is not Boolean, got %T (%+v)", input, actual, actual)
+		if ok {
+			assert.Equal(t, expected, boolean.Value, input)
+		}
+	case string:
+		str, ok := actual.(*object.String)
+		assert.True(t, ok, "%s: object is not String, got %T (%+v)", input, actual, actual)
+		if ok {
+			assert.Equal(t, expected, str.Value, input)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		assert.True(t, ok, "%s: object is not Array, got %T (%+v)", input, actual, actual)
+		if ok {
+			assert.Len(t, array.Elements, len(expected), input)
+			for i, el := range expected {
+				testExpectedObject(t, input, el, array.Elements[i])
+			}
+		}
+	case *object.Null:
+		assert.Equal(t, Null, actual, input)
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"2 + 10 % 4 * 3", 8},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"7 ** 0", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1", 2.5},
+		{"1 - 0.5", 0.5},
+		{"2.5 * 2", 5.0},
+		{"1 / 4.0", 0.25},
+		{"5.5 % 2", 1.5},
+		{"2.0 ** 0.5 ** 2", 1.189207115002721},
+		{"1e3 + 0x10", 1016.0},
+		{"1.5 < 2", true},
+		{"1.5 > 2", false},
+		{"2.0 <= 2", true},
+		{"2.5 >= 2", true},
+		{"let n = (0.0 - 1.0) ** 0.5; n >= 1", false},
+		{"let n = (0.0 - 1.0) ** 0.5; n <= 1", false},
+		{"let n = (0.0 - 1.0) ** 0.5; n < 1", false},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 != 0.3", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == false", false},
+		{"(1 < 2) == true", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!(if (false) { 5; })", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"true || false", true},
+		{"false || false", false},
+		{"1 && 2", true},
+		{"1 < 2 && 2 < 3 || false", true},
+		{"if (true && false) { 1 } else { 2 } == 2", true},
+		{"let f = fn() { 1 / 0 }; false && f()", false},
+		{"let f = fn() { 1 / 0 }; true || f()", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"monkey"`, "monkey"},
+		{`"mon" + "key"`, "monkey"},
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[1, 2 * 3][fn(x) { x }(1)]", 6},
+		{"[][0]", Null},
+		{"[1, 2, 3][99]", Null},
+		{"[1][-1]", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	program := parser.New(lexer.New(`{"d": 1, "a": 2, "c": 1 + 2, "b": 4, "a": 5}`)).ParseProgram()
+
+	comp := compiler.New()
+	assert.NoError(t, comp.Compile(program))
+
+	vm := New(comp.Bytecode())
+	assert.NoError(t, vm.Run())
+
+	// pairs keep the order of the literal
+	assert.Equal(t, "{d: 1, a: 5, c: 3, b: 4}", vm.LastPoppedStackElem().Inspect())
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1, 2: 2}[2]", 2},
+		{`{"a" + "b": 3}["ab"]`, 3},
+		{"{true: 5}[true]", 5},
+		{"{1: 1}[0]", Null},
+		{"{}[0]", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 } ", 20},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", Null},
+		{"if (false) { 10 }", Null},
+		{"if (true) { }", Null},
+		{"if (true) { let a = 1; }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (false) { 10 } else if (true) { 20 } else { 30 }", 20},
+		{"if (false) { 10 } else if (false) { 20 } else { 30 }", 30},
+		{"if (false) { 10 } else if (false) { 20 }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (true) { if (i == 3) { break; } let i = i + 1; }; i", 3},
+		{"let n = 0; for (; n < 3;) { let n = n + 1; }; n", 3},
+		{"let n = 0; for (let i = 0; n < 3; n) { let n = n + 1; }; n", 3},
+		{"let n = 0; for (;;) { break; let n = 1; }; n", 0},
+		{"let sum = 0; for x in [1, 2, 3] { let sum = sum + x; }; sum", 6},
+		{"let sum = 0; for x in [1, 2, 3, 4] { if (x % 2 == 0) { continue; } let sum = sum + x; }; sum", 4},
+		{"let sum = 0; for x in [1, 2, 3] { for y in [10, 20] { if (y > 10) { break; } let sum = sum + y; } }; sum", 30},
+		{`let s = ""; for c in "abc" { let s = c + s; }; s`, "cba"},
+		{`let s = ""; for k in {"a": 1, "b": 2} { let s = s + k; }; s`, "ab"},
+		{"let f = fn(xs) { for x in xs { if (x > 1) { return x; } } }; f([1, 5, 7])", 5},
+		{"let f = fn(xs) { for x in xs { x } }; f([1])", Null},
+		{"let f = fn() { let i = 0; while (i < 2) { let i = i + 1; } }; f()", Null},
+		{"let f = fn() { let n = 0; for (let i = 0; i < 3; n) { let n = n + 1; let i = n; }; n }; f()", 3},
+		{"let f = fn(xs) { let n = 0; for x in xs { for y in xs { let n = n + x * y; } }; n }; f([1, 2])", 9},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`switch (2) { case 1: "a"; case 2, 3: "b"; default: "c" }`, "b"},
+		{`switch (5) { case 1: "a"; default: "c" }`, "c"},
+		{`switch (5) { default: "c"; case 5: "five" }`, "five"},
+		{`switch (5) { case 1: "a" }`, Null},
+		{"switch (1) { case 1: }", Null},
+		{"switch (1) { }", Null},
+		{`switch (1.0) { case 1: "one" }`, "one"},
+		{`switch ("x") { case 1: 1; case "x": 2 }`, 2},
+		{`let x = 15; switch { case x > 10: "big"; case x > 5: "medium"; default: "small" }`, "big"},
+		{`let x = 7; switch { case x > 10: "big"; case x > 5: "medium"; default: "small" }`, "medium"},
+		{`switch (1) { case 1: "a"; case 1 / 0: "b" }`, "a"},
+		{"let n = 0; for x in [1, 2, 3, 4] { switch (x) { case 3: break; default: n += x } }; n", 3},
+		{"let n = 0; for x in [1, 2, 3] { switch (x) { case 2: continue; } n += x }; n", 4},
+		{`let f = fn(x) { switch (x) { case 1: return "one"; } "other" }; f(1) + f(2)`, "oneother"},
+		{"let f = fn(x) { let y = switch (x) { case 1: 10; default: 20 }; y + 1 }; f(1) + f(2)", 32},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`match 0 { 0 => "zero", _ => "other" }`, "zero"},
+		{`match 5 { 0 => "zero", _ => "other" }`, "other"},
+		{`match 5 { 0 => "zero" }`, Null},
+		{`match -1 { -1 => "minus one", _ => "other" }`, "minus one"},
+		{`match 1.0 { 1 => "one" }`, "one"},
+		{`match "hi" { "hi" => 1, _ => 2 }`, 1},
+		{"match true { false => 0, true => 1 }", 1},
+		{"match [1, 2, 3] { [x, ...rest] => rest[1] }", 3},
+		{"match [1, 2] { [a] => a, [a, b] => a + b }", 3},
+		{"match [1, 2] { [_, _, _] => 0, [...] => 1 }", 1},
+		{`match [1] { [1] => "one", _ => "other" }`, "one"},
+		{`match {"k": 5, "j": 1} { {"k": v} => v }`, 5},
+		{`match {"name": "x", "age": 3} { {name, ...rest} => rest["age"] }`, 3},
+		{`match {"a": 1} { {"b": v} => v, _ => 0 }`, 0},
+		{`match 15 { n if n > 10 => "big", n => "small" }`, "big"},
+		{`match 5 { n if n > 10 => "big", n => "small" }`, "small"},
+		{`match [[1, 2], {"x": 3}] { [[a, b], {x}] => a + b + x }`, 6},
+		{"let x = 1; match 5 { x => x }; x", 5},
+		{"let f = fn(v) { match v { [a, b] => a * b, n if n > 0 => n, _ => -1 } }; f([2, 3]) + f(4) + f(-5)", 9},
+		{"let n = 0; for x in [1, [2, 3], 4] { n += match x { [a, b] => a * b, _ => x } }; n", 11},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x = 2", 2},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 1; x -= 2; x", -1},
+		{"let x = 3; x *= 2; x", 6},
+		{"let x = 7; x /= 2; x", 3},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let a = 0; let b = 0; a = b = 2; a + b", 4},
+		{"let a = [1, 2]; a[0] = 5; a[0]", 5},
+		{"let a = [1, 2]; a[1] += 3; a[1]", 5},
+		{`let h = {}; h["k"] = 1; h["k"] += 1; h["k"]`, 2},
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{"let i = 0; let s = 0; while (i < 4) { i += 1; s += i; }; s", 10},
+		{"let s = 0; for (let i = 0; i < 4; i += 1) { s += i; }; s", 6},
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+		{"let f = fn(x) { x *= 2; x }; f(3)", 6},
+		{"let x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"let f = fn() { f = 5; 1 }; f() + f", 6},
+		{"let a = [[1]]; let f = fn() { a[0][0] += 1 }; f(); f(); a[0][0]", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let x = 1; let x = x + 1; x", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; rest[1]", 3},
+		{"let [_, x] = [1, 2]; x", 2},
+		{`let {name, age} = {"name": "x", "age": 3}; age`, 3},
+		{`let {"k": [x, y]} = {"k": [4, 5]}; x * y`, 20},
+		{`let {a, ...others} = {"a": 1, "b": 2}; others["b"]`, 2},
+		{"let a = 1; let b = 2; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([5, 3])", 2},
+		{"let n = 0; for pair in [[1, 2], [3, 4]] { let [a, b] = pair; n += a * b }; n", 14},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let a = fn() { 1 }; let b = fn() { a() + 1 }; b();", 2},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let bareReturn = fn() { return; 1 }; bareReturn();", Null},
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);", 10},
+		{"let globalNum = 10; let sum = fn(a, b) { let c = a + b; c + globalNum; }; sum(1, 2);", 13},
+		{"fn(x){ let x = x + 1; x }(1)", 2},
+		{"let a = 1; let b = fn(){ let a = a; a }; b()", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a, b = a * 2) { a + b }; f(3)", 9},
+		{"let n = 0; let f = fn(x = n += 1) { x }; f(); f(5); f()", 2},
+		{"fn(a, f = fn() { a }) { f() }(4)", 4},
+		{"let sum = fn(n, acc = 0) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(4)", 10},
+		{"let f = fn(a, ...rest) { match rest { [] => 0, _ => 1 } }; f(1)", 0},
+		{"let f = fn(a, ...rest) { rest[1] }; f(1, 2, 3)", 3},
+		{"let f = fn(a, b) { a - b }; f(b: 1, a: 5)", 4},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c: 5)", 125},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(...[1, 2, 3])", 123},
+		{"let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(1, ...[], ...[2], 3)", 123},
+		{"let f = fn(...xs) { let [a, b, c] = xs; c }; f(1, ...[2, 3])", 3},
+		{"let f = fn(a, ...rest) { rest }; f(...[1, 2])[0]", 2},
+		{"let f = fn(a, b = 1) { [a, b] }; f(...[7], b: 8)[1]", 8},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 5;", 5},
+		{"return 5; 6;", 5},
+		{"1; return;", Null},
+		{"if (true) { return 7; } 8;", 7},
+		{"let f = fn() { return 1; }; return f() + 1; 3;", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`let newClosure = fn(a) { fn() { a; }; };
+			let closure = newClosure(99);
+			closure();`,
+			99,
+		},
+		{
+			`let newAdder = fn(a, b) { let c = a + b; fn(d) { c + d }; };
+			let adder = newAdder(1, 2);
+			adder(8);`,
+			11,
+		},
+		{
+			`let newAdderOuter = fn(a, b) {
+				let c = a + b;
+				fn(d) { let e = d + c; fn(f) { e + f; }; };
+			};
+			let newAdderInner = newAdderOuter(1, 2);
+			let adder = newAdderInner(3);
+			adder(8);`,
+			14,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`let fibonacci = fn(x) {
+				if (x < 2) { return x; }
+				fibonacci(x - 1) + fibonacci(x - 2);
+			};
+			fibonacci(15);`,
+			610,
+		},
+		{
+			`let wrapper = fn() {
+				let countDown = fn(x) {
+					if (x == 0) { return 0; }
+					countDown(x - 1);
+				};
+				countDown(1);
+			};
+			wrapper();`,
+			0,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a) { a }();", "wrong number of arguments: want=1, got=0"},
+		{"1();", "calling non-function"},
+		{"let f = fn(a, b = 1) { a }; f()", "missing argument a"},
+		{"let f = fn(a, b = 1) { a }; f(1, 2, 3)", "wrong number of arguments: want=2, got=3"},
+		{"let f = fn(x) { x }; f(y: 1)", "unknown parameter y"},
+		{"let f = fn(x) { x }; f(1, x: 1)", "argument x is passed more than once"},
+		{"let f = fn(x) { x }; f(...1)", "cannot spread INTEGER"},
+		{"let f = fn(x, y) { x }; f(1, ...2)", "cannot spread INTEGER"},
+		{"1(x: 1)", "calling non-function"},
+		{"-true", "unsupported type for negation: BOOLEAN"},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"1.5 / 0", "division by zero"},
+		{"2 ** -1", "negative exponent: -1"},
+		{"for x in 5 { x }", "not iterable: INTEGER"},
+		{"let [a, b] = [1]; a", "cannot destructure ARRAY with [a, b]"},
+		{"let {a} = 1; a", "cannot destructure INTEGER with {a}"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{`"abc"[0] = "x"`, "index assignment not supported: STRING"},
+		{"let x = 1; x += true", "unsupported types for binary operation: INTEGER BOOLEAN"},
+		{`let h = {}; h["k"] += 1`, "unsupported types for binary operation: NULL INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		comp := compiler.New()
+		assert.NoError(t, comp.Compile(program))
+
+		vm := New(comp.Bytecode())
+		assert.EqualError(t, vm.Run(), tt.expected, tt.input)
+	}
+}
+
+func TestUnsetGlobal(t *testing.T) {
+	symbols := compiler.NewSymbolTable()
+	globals := make([]object.Object, GlobalsSize)
+
+	// x is defined, but the line fails before setting it
+	var err error
+	for _, line := range []string{"let x = 1 / 0;", "x"} {
+		comp := compiler.NewWithState(symbols, []object.Object{})
+		assert.NoError(t, comp.Compile(parser.New(lexer.New(line)).ParseProgram()))
+
+		err = NewWithGlobalsStore(comp.Bytecode(), globals).Run()
+	}
+
+	assert.EqualError(t, err, "global 0 is not set")
+}